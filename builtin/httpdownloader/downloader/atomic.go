@@ -0,0 +1,132 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// errPartialLocked is returned when the partial file of a
+// destination is in use by another download
+var errPartialLocked = errors.New("partial download is in use")
+
+// atomicFile is a file which is written next to its destination
+// and only moved into place once it is complete, so a partially
+// written file is never observed at the destination.
+type atomicFile struct {
+	*os.File
+
+	dest     string
+	mode     os.FileMode
+	partial  bool
+	released bool
+}
+
+// partialPath returns the path used to store a resumable
+// download of the destination
+func partialPath(dest string) string {
+	return dest + ".part"
+}
+
+// newAtomicFile creates a uniquely named temporary file in
+// the directory of the destination
+func newAtomicFile(dest string, mode os.FileMode) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, dest: dest, mode: mode}, nil
+}
+
+// openPartialFile opens and locks the file used for a resumable
+// download of the destination. New data is written after any existing
+// content. If another download holds the lock errPartialLocked is
+// returned. The lock is held until the file is committed, aborted or
+// discarded, and the partial file is kept when the download is aborted
+// so it can be resumed later.
+func openPartialFile(dest string, mode os.FileMode) (*atomicFile, error) {
+	p := partialPath(dest)
+	for {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+		if err != nil {
+			return nil, err
+		}
+		if err = lockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		// The download which held the lock may have moved the file
+		// into place or removed it, so it must be opened again
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(p); err == nil && os.SameFile(info, current) {
+			return &atomicFile{File: f, dest: dest, mode: mode, partial: true}, nil
+		}
+		f.Close()
+	}
+}
+
+// Commit flushes the file to disk and moves it into place
+// at the destination
+func (a *atomicFile) Commit() error {
+	if err := a.Sync(); err != nil {
+		a.Close()
+		return err
+	}
+	if err := os.Chmod(a.Name(), a.mode); err != nil {
+		a.Close()
+		return err
+	}
+	return a.release(func(p string) error {
+		return os.Rename(p, a.dest)
+	})
+}
+
+// Abort closes the file without moving it into place. Temporary
+// files are removed while partial files are kept for resuming,
+// unless they are empty. Abort does nothing once the file has
+// been committed or discarded.
+func (a *atomicFile) Abort() {
+	if a.released {
+		return
+	}
+	if a.partial {
+		if info, err := a.Stat(); err == nil && info.Size() > 0 {
+			a.released = true
+			a.Close()
+			return
+		}
+	}
+	a.Discard()
+}
+
+// Discard closes and removes the file
+func (a *atomicFile) Discard() {
+	a.release(os.Remove)
+}
+
+// release closes the file and applies fn to its path. Where the
+// platform allows it, partial files are moved or removed before
+// they are closed so their lock is held until then. Otherwise a
+// concurrent download could lock the file before it is replaced.
+func (a *atomicFile) release(fn func(string) error) error {
+	a.released = true
+	if a.partial && moveOpenFiles {
+		err := fn(a.Name())
+		if cerr := a.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+	if err := a.Close(); err != nil {
+		return err
+	}
+	return fn(a.Name())
+}
@@ -0,0 +1,207 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bufio"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// defaultServerURL is the Vagrant Cloud server used when
+	// VAGRANT_SERVER_URL is not set
+	defaultServerURL = "https://vagrantcloud.com"
+	// cloudTokenFile is the name of the file within the Vagrant
+	// data directory which stores the Vagrant Cloud login token
+	cloudTokenFile = "vagrant_login_token"
+)
+
+// authenticate adds credentials to the request. Credentials are
+// used in the following order of precedence: an Authorization header
+// provided in the configured headers, the bearer token, the username
+// and password, a matching netrc entry and finally the Vagrant Cloud
+// access token when the request is for the Vagrant Cloud server.
+func (d *Downloader) authenticate(req *http.Request) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	switch {
+	case d.config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+d.config.BearerToken)
+	case d.config.Username != "":
+		req.SetBasicAuth(d.config.Username, d.config.Password)
+	case req.URL.User != nil:
+		// Credentials provided within the URL are used
+		// by the http client directly
+	default:
+		if valueOf(d.config.UseNetrc) {
+			if login, password, ok := netrcCredentials(d.config.NetrcPath, req.URL.Hostname()); ok {
+				req.SetBasicAuth(login, password)
+				return
+			}
+		}
+		if token := cloudToken(req.URL); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// cloudToken returns the Vagrant Cloud access token if the URL is
+// for the Vagrant Cloud server. The token is read from the same
+// locations used by the cloud command.
+func cloudToken(u *url.URL) string {
+	// The token is provided as a query parameter instead
+	if os.Getenv("VAGRANT_SERVER_ACCESS_TOKEN_BY_URL") != "" {
+		return ""
+	}
+
+	serverURL := os.Getenv("VAGRANT_SERVER_URL")
+	if serverURL == "" {
+		serverURL = defaultServerURL
+	}
+	server, err := url.Parse(serverURL)
+	if err != nil || server.Hostname() == "" || server.Hostname() != u.Hostname() {
+		return ""
+	}
+
+	if token := os.Getenv("VAGRANT_CLOUD_TOKEN"); token != "" {
+		return token
+	}
+	if home := vagrantHome(); home != "" {
+		if data, err := os.ReadFile(filepath.Join(home, "data", cloudTokenFile)); err == nil {
+			if token := strings.TrimSpace(string(data)); token != "" {
+				return token
+			}
+		}
+	}
+
+	return os.Getenv("ATLAS_TOKEN")
+}
+
+// vagrantHome returns the path to the Vagrant home directory
+func vagrantHome() string {
+	if home := os.Getenv("VAGRANT_HOME"); home != "" {
+		return home
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".vagrant.d")
+}
+
+// netrcPath returns the path of the netrc file to use
+func netrcPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if path = os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name)
+}
+
+// netrcCredentials returns the login and password for the host from
+// the netrc file. If no entry for the host exists the default entry
+// is used when present.
+func netrcCredentials(path, host string) (login, password string, ok bool) {
+	path = netrcPath(path)
+	if path == "" {
+		return "", "", false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", false
+	}
+	defer f.Close()
+
+	type entry struct {
+		login, password string
+	}
+	var current, match, def *entry
+	var inMacro bool
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Macro definitions continue until the next blank line
+		if inMacro {
+			inMacro = strings.TrimSpace(scanner.Text()) != ""
+			continue
+		}
+
+		fields := strings.Fields(scanner.Text())
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				current = &entry{}
+				if i++; i < len(fields) && fields[i] == host && match == nil {
+					match = current
+				}
+			case "default":
+				current = &entry{}
+				if def == nil {
+					def = current
+				}
+			case "login":
+				if i++; i < len(fields) && current != nil {
+					current.login = fields[i]
+				}
+			case "password":
+				if i++; i < len(fields) && current != nil {
+					current.password = fields[i]
+				}
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	if match == nil {
+		match = def
+	}
+	if match == nil {
+		return "", "", false
+	}
+	return match.login, match.password, true
+}
+
+// redactURL returns the URL as a string with any credentials removed
+// so it can be safely logged or included in errors
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	r := *u
+	if q := r.Query(); q.Has("access_token") {
+		q.Set("access_token", "REDACTED")
+		r.RawQuery = q.Encode()
+	}
+	return r.Redacted()
+}
+
+// redactRawURL is redactURL for a URL which has not been parsed
+func redactRawURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	return redactURL(u)
+}
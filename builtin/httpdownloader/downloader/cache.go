@@ -0,0 +1,242 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cache is an on disk cache of downloaded files. Entries are keyed
+// by URL and store the validators of the response so they can be
+// revalidated with a conditional request.
+type cache struct {
+	dir     string
+	maxSize int64
+}
+
+// cacheEntry is the metadata stored for a cached file
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Size         int64     `json:"size"`
+	LastUsed     time.Time `json:"last_used"`
+
+	key string
+}
+
+// cache returns the cache for the downloader, or nil
+// if caching is not enabled. Decompressed downloads are
+// not cached as the cached copy could not be verified
+// against the checksum of the downloaded data.
+func (d *Downloader) cache() *cache {
+	if d.config.CacheDir == "" || valueOf(d.config.Decompress) {
+		return nil
+	}
+	return &cache{
+		dir:     d.config.CacheDir,
+		maxSize: valueOf(d.config.CacheMaxSize),
+	}
+}
+
+// cacheKey returns the key used to store the URL
+func cacheKey(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *cache) dataPath(key string) string {
+	return filepath.Join(c.dir, key)
+}
+
+func (c *cache) metaPath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Lookup returns the entry for the URL. If no entry exists, or the
+// cached file is missing or incomplete, nil is returned.
+func (c *cache) Lookup(u string) *cacheEntry {
+	key := cacheKey(u)
+	e, err := c.load(key)
+	if err != nil || e.URL != redactRawURL(u) {
+		return nil
+	}
+	info, err := os.Stat(c.dataPath(key))
+	if err != nil || info.Size() != e.Size {
+		return nil
+	}
+	return e
+}
+
+func (c *cache) load(key string) (*cacheEntry, error) {
+	data, err := os.ReadFile(c.metaPath(key))
+	if err != nil {
+		return nil, err
+	}
+	var e cacheEntry
+	if err = json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	e.key = key
+	return &e, nil
+}
+
+func (c *cache) save(e *cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.metaPath(e.key), data)
+}
+
+// Open opens the cached file of the entry and marks it as used
+func (c *cache) Open(e *cacheEntry) (*os.File, error) {
+	f, err := os.Open(c.dataPath(e.key))
+	if err != nil {
+		return nil, err
+	}
+	e.LastUsed = time.Now()
+	c.save(e)
+	return f, nil
+}
+
+// Remove deletes the entry from the cache
+func (c *cache) Remove(e *cacheEntry) {
+	os.Remove(c.metaPath(e.key))
+	os.Remove(c.dataPath(e.key))
+}
+
+// Store adds a copy of the file at the given path to the cache
+// using the validators of the response. Responses without
+// validators, or which forbid storage, are not cached.
+func (c *cache) Store(u string, resp *http.Response, path string) error {
+	if !cacheable(resp) {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	key := cacheKey(u)
+	dst, err := newAtomicFile(c.dataPath(key), 0644)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(dst, src)
+	if err != nil {
+		dst.Abort()
+		return err
+	}
+	if err = dst.Commit(); err != nil {
+		dst.Discard()
+		return err
+	}
+
+	e := &cacheEntry{
+		URL:          redactRawURL(u),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Size:         size,
+		LastUsed:     time.Now(),
+		key:          key,
+	}
+	if err = c.save(e); err != nil {
+		return err
+	}
+
+	return c.evict()
+}
+
+// evict removes the least recently used entries until the
+// total size of the cache is within the maximum size
+func (c *cache) evict() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	var entries []*cacheEntry
+	var total int64
+	for _, m := range matches {
+		e, err := c.load(strings.TrimSuffix(filepath.Base(m), ".json"))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+		total += e.Size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		c.Remove(e)
+		total -= e.Size
+	}
+
+	return nil
+}
+
+// SetConditional adds the conditional request headers
+// for the entry to the header
+func (e *cacheEntry) SetConditional(h http.Header) {
+	if e.ETag != "" {
+		h.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		h.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// cacheable returns if the response can be stored in the cache
+func cacheable(resp *http.Response) bool {
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return false
+	}
+	for _, d := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(d), "no-store") {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic writes the data to a temporary file
+// which is then moved into place at the path
+func writeFileAtomic(path string, data []byte) error {
+	f, err := newAtomicFile(path, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Discard()
+		return err
+	}
+	if err = f.Commit(); err != nil {
+		f.Discard()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadCache(t *testing.T) {
+	s := newTestServer(t)
+	dir := t.TempDir()
+	d := &Downloader{config: DownloaderConfig{
+		Src:      s.URL,
+		Dest:     filepath.Join(dir, "box"),
+		CacheDir: filepath.Join(dir, "cache"),
+	}}
+
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if result.Cached {
+		t.Fatal("expected the first download to not be cached")
+	}
+
+	if err = os.Remove(d.config.Dest); err != nil {
+		t.Fatal(err)
+	}
+	s.reset()
+	if result, err = d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if !result.Cached {
+		t.Fatal("expected the second download to be cached")
+	}
+	if statuses := s.statusCodes(); len(statuses) != 1 || statuses[0] != http.StatusNotModified {
+		t.Fatalf("expected the cache to be revalidated, got statuses %v", statuses)
+	}
+
+	// A changed source is downloaded again
+	s.m.Lock()
+	s.etag = `"v2"`
+	s.m.Unlock()
+	if err = os.Remove(d.config.Dest); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if result.Cached {
+		t.Fatal("expected the changed source to not be cached")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// checksumTypes are the supported checksum types
+var checksumTypes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// checksum computes the digest of a download as it is written and
+// validates it against the expected value.
+type checksum struct {
+	hash.Hash
+
+	Type     string
+	Expected string
+}
+
+// newChecksum creates a new checksum for the given type and expected
+// value. The value may be prefixed with the type, like "sha256:abc...",
+// and when no type is given at all it is inferred from the length of
+// the value.
+func newChecksum(kind, value string) (*checksum, error) {
+	value = strings.TrimSpace(value)
+	if k, v, ok := strings.Cut(value, ":"); ok {
+		kind, value = k, v
+	}
+
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind == "" {
+		kind = checksumTypeForLength(len(value))
+	}
+	fn, ok := checksumTypes[kind]
+	if !ok {
+		return nil, &ChecksumTypeError{Type: kind}
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return nil, fmt.Errorf("invalid %s checksum %q", kind, value)
+	}
+	if size := fn().Size() * 2; len(value) != size {
+		return nil, fmt.Errorf("invalid %s checksum %q, expected %d hex characters", kind, value, size)
+	}
+
+	return &checksum{
+		Hash:     fn(),
+		Type:     kind,
+		Expected: strings.ToLower(value),
+	}, nil
+}
+
+// newDigest creates a checksum which only computes the sha256
+// digest of the data written without validating it
+func newDigest() *checksum {
+	return &checksum{
+		Hash: sha256.New(),
+		Type: "sha256",
+	}
+}
+
+// verifies returns if the checksum has an expected value
+// which the data written is validated against
+func (c *checksum) verifies() bool {
+	return c != nil && c.Expected != ""
+}
+
+// checksumTypeForLength returns the checksum type with a hex
+// encoded digest of the given length
+func checksumTypeForLength(l int) string {
+	for kind, fn := range checksumTypes {
+		if fn().Size()*2 == l {
+			return kind
+		}
+	}
+	return ""
+}
+
+// Actual returns the hex encoded digest of the data written so far
+func (c *checksum) Actual() string {
+	return hex.EncodeToString(c.Sum(nil))
+}
+
+// Verify checks the digest of the data written against the
+// expected value. Nothing is checked when there is no expected
+// value.
+func (c *checksum) Verify() error {
+	if !c.verifies() {
+		return nil
+	}
+	if actual := c.Actual(); actual != c.Expected {
+		return &ChecksumError{
+			Type:     c.Type,
+			Expected: c.Expected,
+			Actual:   actual,
+		}
+	}
+	return nil
+}
+
+// ReadFile writes the contents of the file at the given path to the
+// digest. This is used to include previously downloaded data when
+// resuming a download.
+func (c *checksum) ReadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(c, f)
+	return err
+}
+
+// parseChecksumFile locates the checksum for the named file within the
+// content of a checksum file. Both the GNU coreutils format ("digest
+// [*]name") and the BSD format ("TYPE (name) = digest") are supported,
+// as well as a file containing only a single digest.
+func parseChecksumFile(r io.Reader, name string) (kind, value string, err error) {
+	name = path.Base(name)
+	var single []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// BSD style
+		if prefix, digest, ok := strings.Cut(line, ") = "); ok {
+			if t, file, ok := strings.Cut(prefix, " ("); ok && path.Base(file) == name {
+				return strings.ToLower(t), strings.TrimSpace(digest), nil
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			single = append(single, fields[0])
+		case 2:
+			file := strings.TrimPrefix(fields[1], "*")
+			if path.Base(file) == name {
+				return "", fields[0], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	if len(single) == 1 {
+		return "", single[0], nil
+	}
+
+	return "", "", fmt.Errorf("no checksum found for %q", name)
+}
+
+// supportedChecksumTypes returns a sorted list of the supported
+// checksum types
+func supportedChecksumTypes() []string {
+	types := make([]string, 0, len(checksumTypes))
+	for k := range checksumTypes {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,147 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestNewChecksum(t *testing.T) {
+	for _, tc := range []struct {
+		kind, value string
+		want        string
+		err         bool
+	}{
+		{value: testSHA256, want: "sha256"},
+		{value: "sha256:" + strings.ToUpper(testSHA256), want: "sha256"},
+		{kind: "SHA256", value: testSHA256, want: "sha256"},
+		{value: "d41d8cd98f00b204e9800998ecf8427e", want: "md5"},
+		{value: "sha256:", err: true},
+		{kind: "sha256", value: "", err: true},
+		{kind: "sha256", value: testSHA256[:62], err: true},
+		{kind: "md5", value: testSHA256, err: true},
+		{kind: "sha256", value: "z" + testSHA256[1:], err: true},
+		{kind: "crc32", value: "00000000", err: true},
+	} {
+		sum, err := newChecksum(tc.kind, tc.value)
+		if tc.err {
+			if err == nil {
+				t.Errorf("expected %q %q to be invalid", tc.kind, tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q %q: %s", tc.kind, tc.value, err)
+			continue
+		}
+		if sum.Type != tc.want {
+			t.Errorf("expected %q %q to be %s, got %s", tc.kind, tc.value, tc.want, sum.Type)
+		}
+	}
+}
+
+func TestValidateChecksum(t *testing.T) {
+	c := &DownloaderConfig{Src: "http://example.com/box", Dest: "box", Checksum: "sha256:"}
+	var cerr *ConfigError
+	if err := c.Validate(); !errors.As(err, &cerr) || cerr.Field != "Checksum" {
+		t.Fatalf("expected the empty checksum to be invalid, got %v", err)
+	}
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	for _, tc := range []struct {
+		name, content string
+		kind, value   string
+		err           bool
+	}{
+		{
+			name:    "GNU",
+			content: "# checksums\n" + testSHA256 + "  other.box\n" + strings.Repeat("a", 64) + " *dir/test.box\n",
+			value:   strings.Repeat("a", 64),
+		},
+		{
+			name:    "BSD",
+			content: "SHA256 (other.box) = " + testSHA256 + "\nSHA512 (test.box) = abc\n",
+			kind:    "sha512",
+			value:   "abc",
+		},
+		{
+			name:    "single",
+			content: "\n" + testSHA256 + "\n",
+			value:   testSHA256,
+		},
+		{
+			name:    "missing",
+			content: testSHA256 + "  other.box\n",
+			err:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kind, value, err := parseChecksumFile(strings.NewReader(tc.content), "/boxes/test.box")
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != tc.kind || value != tc.value {
+				t.Fatalf("expected %q %q, got %q %q", tc.kind, tc.value, kind, value)
+			}
+		})
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	s := newTestServer(t)
+	d := &Downloader{config: DownloaderConfig{
+		Src:      s.URL,
+		Dest:     filepath.Join(t.TempDir(), "box"),
+		Checksum: "sha256:" + testSHA256,
+	}}
+
+	_, err := d.Download(DownloadInput{})
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) || cerr.Expected != testSHA256 {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+	if _, err = os.Stat(d.config.Dest); !os.IsNotExist(err) {
+		t.Fatalf("expected no file at the destination, got %v", err)
+	}
+}
+
+func TestDownloadChecksumURL(t *testing.T) {
+	sum := sha256.Sum256(testContent)
+	content := newTestServer(t)
+	sums := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(hex.EncodeToString(sum[:]) + "  box\n"))
+	}))
+	t.Cleanup(sums.Close)
+
+	d := &Downloader{config: DownloaderConfig{
+		Src:         content.URL + "/box",
+		Dest:        filepath.Join(t.TempDir(), "box"),
+		ChecksumURL: sums.URL + "/SHA256SUMS",
+	}}
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if result.Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected checksum %q", result.Checksum)
+	}
+}
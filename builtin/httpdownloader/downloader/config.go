@@ -0,0 +1,179 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// defaultRetryCount is the number of times a failed request
+// is retried when no retry count is configured
+const defaultRetryCount = 3
+
+var headerType = reflect.TypeOf(http.Header{})
+
+// mergeConfig returns the result of applying the overlay to the
+// base configuration. Fields which are set in the overlay replace
+// the value of the base, except for maps which are merged with the
+// overlay taking precedence for keys present in both. Optional
+// fields are pointers so an overlay can also set a zero value, such
+// as false or GET, while empty strings and slices are never set.
+func mergeConfig[T any](base, overlay *T) *T {
+	result := new(T)
+	if base != nil {
+		*result = *base
+	}
+	if overlay == nil {
+		return result
+	}
+
+	rv := reflect.ValueOf(result).Elem()
+	ov := reflect.ValueOf(overlay).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		field := ov.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		if field.Kind() != reflect.Map {
+			rv.Field(i).Set(field)
+			continue
+		}
+
+		// Maps are copied so the base and overlay are not modified
+		merged := reflect.MakeMap(field.Type())
+		for _, m := range []reflect.Value{rv.Field(i), field} {
+			iter := m.MapRange()
+			for iter.Next() {
+				key := iter.Key()
+				// Header names are case insensitive
+				if field.Type() == headerType {
+					key = reflect.ValueOf(http.CanonicalHeaderKey(key.String()))
+				}
+				merged.SetMapIndex(key, iter.Value())
+			}
+		}
+		rv.Field(i).Set(merged)
+	}
+
+	return result
+}
+
+// finalizeConfig applies default values to the configuration
+func finalizeConfig(c *DownloaderConfig) {
+	if c.RetryCount == nil {
+		n := defaultRetryCount
+		c.RetryCount = &n
+	}
+	c.Headers = canonicalHeader(c.Headers)
+}
+
+// canonicalHeader returns a copy of the header with
+// canonical header names
+func canonicalHeader(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	h := make(http.Header, len(header))
+	for k, v := range header {
+		h[http.CanonicalHeaderKey(k)] = v
+	}
+	return h
+}
+
+// Validate checks the configuration for invalid values. All
+// problems are reported, each as a *ConfigError.
+func (c *DownloaderConfig) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, &ConfigError{
+			Namespace: "downloader",
+			Field:     field,
+			Reason:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	if c.Src == "" {
+		invalid("Src", "must be set")
+	} else if err := validateSource(c.Src); err != nil {
+		invalid("Src", "%s", err)
+	}
+	for i, m := range c.Mirrors {
+		if err := validateSource(m); err != nil {
+			invalid(fmt.Sprintf("Mirrors[%d]", i), "%s", err)
+		}
+	}
+	if c.ChecksumURL != "" {
+		if err := validateSource(c.ChecksumURL); err != nil {
+			invalid("ChecksumURL", "%s", err)
+		}
+	}
+	if c.Dest == "" {
+		invalid("Dest", "must be set")
+	}
+	if method := valueOf(c.Method); !method.valid() {
+		invalid("Method", "is not a known HTTP method (%s)", method)
+	}
+	for method := range c.AcceptedStatusCodes {
+		if !method.valid() {
+			invalid("AcceptedStatusCodes", "contains unknown HTTP method (%s)", method)
+		}
+	}
+	if c.Checksum != "" {
+		if _, err := newChecksum(c.ChecksumType, c.Checksum); err != nil {
+			invalid("Checksum", "is invalid: %s", err)
+		}
+	}
+	for _, p := range []struct{ field, value string }{
+		{"HTTPProxy", c.HTTPProxy},
+		{"HTTPSProxy", c.HTTPSProxy},
+	} {
+		if p.value == "" {
+			continue
+		}
+		if u, err := url.Parse(p.value); err != nil || u.Host == "" {
+			invalid(p.field, "is not a valid proxy URL")
+		}
+	}
+	if valueOf(c.Segments) < 0 {
+		invalid("Segments", "must not be negative")
+	}
+	if valueOf(c.RateLimit) < 0 {
+		invalid("RateLimit", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateSource checks that the source is a valid location
+// which can be downloaded
+func validateSource(src string) error {
+	u, err := parseSource(src)
+	if err != nil {
+		return errors.New("is not a valid URL")
+	}
+	if isHTTP(u) {
+		if u.Host == "" {
+			return fmt.Errorf("is missing a host: %s", redactURL(u))
+		}
+		return nil
+	}
+	if _, ok := lookupScheme(u.Scheme); !ok {
+		return fmt.Errorf("has unsupported URL scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+// valueOf returns the value of an optional configuration
+// field, or the zero value when it is not set
+func valueOf[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,39 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewDownloader(t *testing.T) {
+	s := newTestServer(t)
+	d, err := NewDownloader(&DownloaderConfig{
+		Src:  s.URL,
+		Dest: filepath.Join(t.TempDir(), "box"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := valueOf(d.config.RetryCount); n != defaultRetryCount {
+		t.Fatalf("expected the default retry count, got %d", n)
+	}
+	if _, err = d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+}
+
+func TestNewDownloaderInvalid(t *testing.T) {
+	d, err := NewDownloader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cerr *ConfigError
+	if _, err = d.Download(DownloadInput{}); !errors.As(err, &cerr) || cerr.Field != "Src" {
+		t.Fatalf("expected Src to be required, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compression describes a supported compression format
+type compression struct {
+	// name of the format used in errors
+	name string
+	// magic is the leading bytes of compressed data
+	magic []byte
+	// encodings are the Content-Encoding values of the format
+	encodings []string
+	// mediaTypes are the Content-Type values of the format
+	mediaTypes []string
+	// reader returns a reader of the decompressed data
+	reader func(io.Reader) (io.ReadCloser, error)
+}
+
+var compressions = []*compression{
+	{
+		name:       "gzip",
+		magic:      []byte{0x1f, 0x8b},
+		encodings:  []string{"gzip", "x-gzip"},
+		mediaTypes: []string{"application/gzip", "application/x-gzip"},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:       "bzip2",
+		magic:      []byte("BZh"),
+		encodings:  []string{"bzip2", "x-bzip2"},
+		mediaTypes: []string{"application/x-bzip2"},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		name:       "xz",
+		magic:      []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		encodings:  []string{"xz", "x-xz"},
+		mediaTypes: []string{"application/x-xz"},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(xr), nil
+		},
+	},
+	{
+		name:       "zstd",
+		magic:      []byte{0x28, 0xb5, 0x2f, 0xfd},
+		encodings:  []string{"zstd"},
+		mediaTypes: []string{"application/zstd"},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+}
+
+// detectCompression returns the compression format of the data. The
+// Content-Encoding and Content-Type of the response are checked first,
+// followed by the leading bytes of the data. If the data is not
+// compressed nil is returned.
+func detectCompression(header http.Header, r *bufio.Reader) *compression {
+	if header != nil {
+		for _, v := range strings.Split(header.Get("Content-Encoding"), ",") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			for _, c := range compressions {
+				for _, e := range c.encodings {
+					if v == e {
+						return c
+					}
+				}
+			}
+		}
+		if mt, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+			for _, c := range compressions {
+				for _, t := range c.mediaTypes {
+					if mt == t {
+						return c
+					}
+				}
+			}
+		}
+	}
+
+	// A short read only means the data is smaller
+	// than the magic bytes being checked
+	lead, _ := r.Peek(6)
+	for _, c := range compressions {
+		if bytes.HasPrefix(lead, c.magic) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// decompressWriter decompresses the data written to it and writes
+// the result to the underlying writer. Decompression is performed
+// in a separate goroutine which reads from a pipe, so Finish must
+// always be called once writing is complete.
+type decompressWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func newDecompressWriter(w io.Writer, header http.Header) *decompressWriter {
+	pr, pw := io.Pipe()
+	dw := &decompressWriter{
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+
+	go func() {
+		err := decompress(w, pr, header)
+		// Unblock any pending writes if decompression stopped early
+		pr.CloseWithError(err)
+		dw.done <- err
+	}()
+
+	return dw
+}
+
+func (w *decompressWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Finish completes decompression. If the written data ended with an
+// error the decompression is aborted and the error is returned,
+// otherwise any error encountered while decompressing is returned.
+func (w *decompressWriter) Finish(err error) error {
+	w.pw.CloseWithError(err)
+	derr := <-w.done
+	if err != nil {
+		return err
+	}
+	return derr
+}
+
+// decompress reads the compressed data from r and writes the
+// decompressed data to w. Data which is not compressed is
+// written unchanged.
+func decompress(w io.Writer, r io.Reader, header http.Header) error {
+	br := bufio.NewReader(r)
+	c := detectCompression(header, br)
+	if c == nil {
+		_, err := io.Copy(w, br)
+		return err
+	}
+
+	cr, err := c.reader(br)
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s data: %w", c.name, err)
+	}
+	defer cr.Close()
+
+	if _, err = io.Copy(w, cr); err != nil {
+		return fmt.Errorf("failed to decompress %s data: %w", c.name, err)
+	}
+
+	// Consume anything following the compressed stream so it
+	// is still included when computing the checksum
+	_, err = io.Copy(io.Discard, br)
+	return err
+}
+
+// destWriter returns the writer which the downloaded data is written
+// to. When decompression is enabled the data is decompressed before
+// it is written to the file and the checksum is computed from either
+// the downloaded or the decompressed data. The returned function must
+// be called with the result of writing and returns the final error.
+func (d *Downloader) destWriter(out io.Writer, sum *checksum, header http.Header) (io.Writer, func(error) error) {
+	if !valueOf(d.config.Decompress) {
+		if sum != nil {
+			out = io.MultiWriter(out, sum)
+		}
+		return out, func(err error) error { return err }
+	}
+
+	if sum != nil && valueOf(d.config.ChecksumDecompressed) {
+		out = io.MultiWriter(out, sum)
+	}
+	dw := newDecompressWriter(out, header)
+	var w io.Writer = dw
+	if sum != nil && !valueOf(d.config.ChecksumDecompressed) {
+		w = io.MultiWriter(sum, dw)
+	}
+
+	return w, dw.Finish
+}
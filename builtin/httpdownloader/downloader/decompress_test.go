@@ -0,0 +1,132 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gzipContent returns the test content compressed with gzip
+func gzipContent(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(testContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// dataServer serves the data with the content type
+func dataServer(t *testing.T, data []byte, contentType string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func hexSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDetectCompression(t *testing.T) {
+	for _, tc := range []struct {
+		data   string
+		header http.Header
+		want   string
+	}{
+		{data: "\x1f\x8bdata", want: "gzip"},
+		{data: "BZh9data", want: "bzip2"},
+		{data: "\xfd7zXZ\x00data", want: "xz"},
+		{data: "\x28\xb5\x2f\xfddata", want: "zstd"},
+		{data: "data", header: http.Header{"Content-Encoding": {"identity, X-Bzip2"}}, want: "bzip2"},
+		{data: "data", header: http.Header{"Content-Type": {"application/zstd; charset=binary"}}, want: "zstd"},
+		{data: "data"},
+		{data: ""},
+	} {
+		var got string
+		if c := detectCompression(tc.header, bufio.NewReader(strings.NewReader(tc.data))); c != nil {
+			got = c.name
+		}
+		if got != tc.want {
+			t.Errorf("%q %v: expected %q, got %q", tc.data, tc.header, tc.want, got)
+		}
+	}
+}
+
+func TestDownloadDecompress(t *testing.T) {
+	for _, format := range []string{"gzip", "none"} {
+		t.Run(format, func(t *testing.T) {
+			data := testContent
+			if format == "gzip" {
+				data = gzipContent(t)
+			}
+			s := dataServer(t, data, "application/octet-stream")
+
+			for _, decompressed := range []bool{false, true} {
+				checksum := hexSum(data)
+				if decompressed {
+					checksum = hexSum(testContent)
+				}
+				d := &Downloader{config: DownloaderConfig{
+					Src:                  s.URL,
+					Dest:                 filepath.Join(t.TempDir(), "box"),
+					Decompress:           ptr(true),
+					Checksum:             checksum,
+					ChecksumDecompressed: ptr(decompressed),
+				}}
+				if _, err := d.Download(DownloadInput{}); err != nil {
+					t.Fatalf("checksum decompressed %t: %s", decompressed, err)
+				}
+				checkContent(t, d.config.Dest)
+			}
+		})
+	}
+}
+
+func TestDownloadDecompressContentType(t *testing.T) {
+	// Only the content type identifies the data as compressed
+	s := dataServer(t, []byte("not gzip data"), "application/gzip")
+	d := &Downloader{config: DownloaderConfig{
+		Src:        s.URL,
+		Dest:       filepath.Join(t.TempDir(), "box"),
+		Decompress: ptr(true),
+	}}
+	_, err := d.Download(DownloadInput{})
+	if err == nil || !strings.Contains(err.Error(), "failed to decompress gzip data") {
+		t.Fatalf("expected decompression error, got %v", err)
+	}
+}
+
+func TestDownloadDecompressDisabled(t *testing.T) {
+	data := gzipContent(t)
+	s := dataServer(t, data, "application/gzip")
+	d := &Downloader{config: DownloaderConfig{
+		Src:  s.URL,
+		Dest: filepath.Join(t.TempDir(), "box"),
+	}}
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checksum != hexSum(data) {
+		t.Fatalf("expected the compressed data to be written")
+	}
+}
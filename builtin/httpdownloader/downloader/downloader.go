@@ -5,12 +5,23 @@ package downloader
 
 import (
 	"bytes"
-	"io/ioutil"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/go-argmapper"
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/hashicorp/vagrant-plugin-sdk/component"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
 )
 
 // Type is an enum of all the available http methods
@@ -22,20 +33,212 @@ const (
 	HEAD
 	POST
 	PUT
+	PATCH
+	OPTIONS
 )
 
+// maxErrorBodySize is the number of bytes of an error response
+// body included in a StatusError
+const maxErrorBodySize = 512
+
+// Downloader fetches a source over HTTP, or with a registered scheme
+// handler, and writes it to a destination. Downloads are available to
+// Go code through a Downloader created with NewDownloader.
 type Downloader struct {
 	config DownloaderConfig
 }
 
+// NewDownloader returns a Downloader for the configuration with
+// default values applied. The configuration is validated when
+// downloading.
+func NewDownloader(conf *DownloaderConfig) (*Downloader, error) {
+	d := &Downloader{}
+	conf, err := d.Finalize(nil, conf)
+	if err != nil {
+		return nil, err
+	}
+	d.config = *conf
+	return d, nil
+}
+
+// DownloadResult describes a completed download
+type DownloadResult struct {
+	// URL is the location the download was fetched from after
+	// following any redirects
+	URL string
+	// Source is the configured source, either Src or one
+	// of the Mirrors, which served the download
+	Source string
+	// StatusCode is the status code of the final response. It is
+	// zero when the source was not fetched over HTTP.
+	StatusCode int
+	// Header is the header of the final response
+	Header http.Header
+	// Cached is true when the download was served from the cache
+	Cached bool
+	// Size is the number of bytes written to Dest
+	Size int64
+	// Checksum is the hex encoded digest of the download and
+	// ChecksumType is the type of the digest. The digest uses the
+	// configured checksum type, or sha256 when no checksum is
+	// configured. It is empty when the digest was not computed,
+	// like when an unverified file was linked into place.
+	Checksum     string
+	ChecksumType string
+	// Elapsed is the time taken by the download including
+	// any retries and failed sources
+	Elapsed time.Duration
+	// Attempt is the number of requests sent for the download,
+	// starting from 1, including retries of failed requests and of
+	// stalled responses. For segmented downloads it is the highest
+	// attempt of any segment.
+	Attempt int
+}
+
+// newResult creates the result of a download from the final
+// response. The digest is included when sum is not nil.
+func newResult(u *url.URL, resp *http.Response, sum *checksum) *DownloadResult {
+	result := &DownloadResult{
+		URL: redactURL(u),
+	}
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		result.Header = resp.Header
+	}
+	if sum != nil {
+		result.Checksum = sum.Actual()
+		result.ChecksumType = sum.Type
+	}
+	return result
+}
+
 type DownloaderConfig struct {
-	Dest           string
-	Headers        http.Header
-	Method         HTTPMethod
-	RetryCount     int
-	RequestBody    []byte
-	Src            string
+	// AcceptedStatusCodes are the response status codes which are
+	// considered successful for a request method. Methods which are
+	// not included accept any 2xx status code.
+	AcceptedStatusCodes map[HTTPMethod][]int
+	// AttemptTimeout limits how long each attempt waits for the
+	// response headers and for each read of the response body. A
+	// stalled body fails with ErrStalled and is retried, continuing
+	// from the data received when the download is resumable.
+	AttemptTimeout *time.Duration
+	// BearerToken is sent in the Authorization header
+	BearerToken string
+	// CACert is a file of PEM encoded CA certificates used to
+	// verify the server instead of the system certificates
+	CACert string
+	// CAPath is a directory of PEM encoded CA certificates used
+	// to verify the server instead of the system certificates
+	CAPath string
+	// CacheDir is the directory used to cache downloads. Cached
+	// downloads are revalidated with the server using conditional
+	// requests and reused when unchanged. Caching is disabled when
+	// not set.
+	CacheDir string
+	// CacheMaxSize is the maximum size of the cache in bytes. The
+	// least recently used downloads are removed when it is exceeded.
+	// A value of zero does not limit the size.
+	CacheMaxSize *int64
+	// Checksum is the expected digest of the downloaded file. It may be
+	// prefixed with the checksum type, like "sha256:...".
+	Checksum string
+	// ChecksumType is the type of Checksum: md5, sha1, sha256, sha384
+	// or sha512. When empty the type is inferred from the checksum.
+	ChecksumType string
+	// ChecksumDecompressed verifies Checksum against the decompressed
+	// data instead of the downloaded data when Decompress is enabled
+	ChecksumDecompressed *bool
+	// ChecksumURL is the location of a checksum file containing the
+	// expected digest of Src. It is used when Checksum is not set.
+	ChecksumURL string
+	// ClientCert is a PEM encoded client certificate file. The
+	// private key may be included or provided with ClientKey.
+	ClientCert string
+	// ClientKey is a PEM encoded private key file for ClientCert
+	ClientKey string
+	// Decompress decompresses gzip, bzip2, xz and zstd downloads while
+	// they are written to Dest. The format is detected from the
+	// Content-Encoding or Content-Type of the response, or from the
+	// leading bytes of the data. Data which is not compressed is
+	// written unchanged. Decompressed downloads are not resumed,
+	// segmented or cached.
+	Decompress *bool
+	Dest       string
+	// FileMode is the mode of the downloaded file. Defaults to 0644.
+	FileMode *os.FileMode
+	// Hardlink links local files into place instead of copying
+	// them. The downloaded file then shares its mode and content
+	// with the source file.
+	Hardlink *bool
+	Headers  http.Header
+	// HTTPProxy is the proxy used for http requests. Defaults to
+	// the HTTP_PROXY environment variable.
+	HTTPProxy string
+	// HTTPSProxy is the proxy used for https requests. Defaults to
+	// the HTTPS_PROXY environment variable.
+	HTTPSProxy string
+	// Insecure disables verification of the server certificate
+	Insecure *bool
+	// MaxRedirects is the maximum number of redirects which are
+	// followed. Defaults to 10, zero or a negative value disables
+	// redirects.
+	MaxRedirects *int
+	Method       *HTTPMethod
+	// Mirrors are additional sources for the download which are
+	// tried in order when the download from Src fails
+	Mirrors []string
+	// NetrcPath is the netrc file used when UseNetrc is enabled.
+	// Defaults to the NETRC environment variable or ~/.netrc.
+	NetrcPath string
+	// NoProxy is a comma separated list of hosts which are not
+	// proxied. Defaults to the NO_PROXY environment variable.
+	NoProxy string
+	// Password is used with Username for basic authentication
+	Password string
+	// RetryCount is the number of times a failed request is retried.
+	// A download whose response stalls is retried with the same
+	// budget. It defaults to 3 when the configuration is finalized,
+	// zero or a negative value disables retries.
+	RetryCount *int
+	// RetryJitter randomizes the wait between retries
+	RetryJitter *bool
+	// RetryStatusCodes are the response status codes which are
+	// retried. Defaults to 429 and 5xx status codes, except 501.
+	RetryStatusCodes []int
+	// RetryWaitMin is the minimum wait between retries. The wait
+	// doubles on each attempt up to RetryWaitMax. A Retry-After
+	// header sent by the server is always honored.
+	RetryWaitMin *time.Duration
+	// RetryWaitMax is the maximum wait between retries
+	RetryWaitMax *time.Duration
+	RequestBody  []byte
+	// RateLimit is the maximum download rate in bytes per second.
+	// The rate is not limited when zero.
+	RateLimit *int64
+	// RateLimitBurst is the number of bytes which may be read at
+	// once while rate limited. Defaults to RateLimit.
+	RateLimitBurst *int
+	// Resume continues a previously interrupted download of Src. The
+	// download is written to a partial file next to Dest until it is
+	// complete.
+	Resume *bool
+	// Segments is the number of concurrent range requests used to
+	// download Src. The download falls back to a single request
+	// when the server does not support ranges. Segmented downloads
+	// cannot be resumed.
+	Segments *int
+	// Src is the location of the download. It may be an http or https
+	// URL, a file URL, a local path or a URL with a registered scheme.
+	Src string
+	// Timeout is the deadline for the complete download,
+	// including all retries
+	Timeout        *time.Duration
 	UrlQueryParams map[string]string
+	// UseNetrc enables looking up credentials for the source
+	// host in the netrc file
+	UseNetrc *bool
+	// Username is used with Password for basic authentication
+	Username string
 }
 
 func (d *Downloader) InitFunc() any {
@@ -62,12 +265,33 @@ func (d *Downloader) Struct() *DownloaderConfig {
 	return &DownloaderConfig{}
 }
 
-func (d *Downloader) Merge(val *component.ConfigMerge) *component.ConfigData {
-	return val.Base
+func (d *Downloader) Merge(
+	input struct {
+		argmapper.Struct
+		Base    *DownloaderConfig
+		Overlay *DownloaderConfig
+		Log     hclog.Logger
+	},
+) (*DownloaderConfig, error) {
+	result := mergeConfig(input.Base, input.Overlay)
+	if input.Log != nil {
+		input.Log.Trace("merged config values in downloader namespace",
+			"src", redactRawURL(result.Src), "dest", result.Dest)
+	}
+
+	return result, nil
 }
 
-func (d *Downloader) Finalize(val *component.ConfigData) *component.ConfigData {
-	return val
+// Finalize applies default values to the configuration. It is not
+// validated here since the namespace is usually not configured at
+// all, the configuration is validated when downloading instead.
+func (d *Downloader) Finalize(l hclog.Logger, conf *DownloaderConfig) (*DownloaderConfig, error) {
+	if conf == nil {
+		conf = &DownloaderConfig{}
+	}
+	finalizeConfig(conf)
+
+	return conf, nil
 }
 
 func (d *Downloader) Register() (*component.ConfigRegistration, error) {
@@ -80,25 +304,180 @@ func (d *Downloader) DownloadFunc() interface{} {
 	return d.Download
 }
 
-func (d *Downloader) Download() (err error) {
+// DownloadInput is the input of Download. The progress of the
+// download is only reported when a UI is provided.
+type DownloadInput struct {
+	argmapper.Struct
+	Context context.Context `argmapper:",typeOnly,optional"`
+	UI      terminal.UI     `argmapper:",typeOnly,optional"`
+	Logger  hclog.Logger    `argmapper:",typeOnly,optional"`
+}
+
+// Download fetches the configured source and writes it to the
+// destination. If a UI is provided the progress of the download
+// is reported to it. Cancelling the context aborts the download.
+func (d *Downloader) Download(input DownloadInput) (result *DownloadResult, err error) {
+	ctx, ui, logger := input.Context, input.UI, input.Logger
+	start := time.Now()
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err = d.config.Validate(); err != nil {
+		return nil, err
+	}
+	if valueOf(d.config.Timeout) > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, valueOf(d.config.Timeout))
+		defer cancel()
+	}
+
+	transport, err := d.transport()
+	if err != nil {
+		return nil, err
+	}
+
 	client := retryablehttp.NewClient()
-	client.RetryMax = d.config.RetryCount
+	// The attempt timeout only applies while waiting on the
+	// connection, not while waiting for the rate limit
+	client.HTTPClient.Transport = d.rateLimit(d.idleTimeout(transport))
+	d.configureRetry(client)
+	// Requests are logged here with any credentials removed
+	// from the URL instead of by the client
+	client.Logger = nil
+	client.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		attempt = recordAttempt(req, attempt+1)
+		logger.Debug("sending request", "method", req.Method,
+			"url", redactURL(req.URL), "attempt", attempt)
+	}
+	// Return the final response when retries are exhausted so
+	// the status can be reported
+	client.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	client.HTTPClient.CheckRedirect = d.checkRedirect
+
+	sum, err := d.expectedChecksum(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(d.config.Dest), 0755); err != nil {
+		return nil, err
+	}
+
+	// Try each source in order until one succeeds. Every source
+	// gets the full retry budget of the client.
+	var errs []error
+	for _, src := range d.sources() {
+		if sum != nil {
+			sum.Reset()
+		}
+		attempts, actx := trackAttempts(ctx)
+		if result, err = d.fetchSource(actx, client, src, sum, ui, logger); err == nil {
+			result.Source = redactRawURL(src)
+			result.Attempt = max(int(attempts.Load()), 1)
+			result.Elapsed = time.Since(start)
+			if info, err := os.Stat(d.config.Dest); err == nil {
+				result.Size = info.Size()
+			}
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		logger.Warn("download failed", "source", redactRawURL(src), "error", err)
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// sources returns the configured sources in the
+// order they should be tried
+func (d *Downloader) sources() []string {
+	return append([]string{d.config.Src}, d.config.Mirrors...)
+}
+
+// fetchSource downloads the source using the HTTP client or
+// the handler registered for the scheme of the source
+func (d *Downloader) fetchSource(
+	ctx context.Context,
+	client *retryablehttp.Client,
+	src string,
+	sum *checksum,
+	ui terminal.UI,
+	logger hclog.Logger,
+) (*DownloadResult, error) {
+	u, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	if isHTTP(u) {
+		return d.fetchStalled(ctx, client, src, sum, ui, logger)
+	}
+
+	h, ok := lookupScheme(u.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return d.fetchScheme(ctx, h, u, sum, ui)
+}
+
+// fetchStalled downloads the source over HTTP like fetch. The client
+// only retries failed requests, so a download which fails when its
+// response body stalls is retried here. Both share one retry budget
+// so no more requests than the configured retry count allows are
+// sent. A resumable download continues from the data received.
+func (d *Downloader) fetchStalled(
+	ctx context.Context,
+	client *retryablehttp.Client,
+	src string,
+	sum *checksum,
+	ui terminal.UI,
+	logger hclog.Logger,
+) (*DownloadResult, error) {
+	budget, ctx := withRetryBudget(ctx)
+	for {
+		result, err := d.fetch(ctx, client, src, sum, ui, logger)
+		if !errors.Is(err, ErrStalled) || budget.exhausted(valueOf(d.config.RetryCount)) {
+			return result, err
+		}
+
+		logger.Warn("download stalled, retrying", "source", redactRawURL(src),
+			"attempt", budget.sent.Load(), "resume", d.resumable())
+		if sum != nil {
+			sum.Reset()
+		}
+	}
+}
+
+// fetch downloads the source over HTTP and writes it to the destination
+func (d *Downloader) fetch(
+	ctx context.Context,
+	client *retryablehttp.Client,
+	src string,
+	sum *checksum,
+	ui terminal.UI,
+	logger hclog.Logger,
+) (result *DownloadResult, err error) {
 	var req *retryablehttp.Request
 
 	// Create request with request body if one is provided
 	if d.config.RequestBody != nil {
-		req, err = retryablehttp.NewRequest(
-			d.config.Method.String(), d.config.Src, bytes.NewBuffer(d.config.RequestBody),
+		req, err = retryablehttp.NewRequestWithContext(
+			ctx, valueOf(d.config.Method).String(), src, bytes.NewBuffer(d.config.RequestBody),
 		)
-		if err != nil {
-			return err
-		}
 	} else {
 		// If no request body is provided then create an empty request
-		req, err = retryablehttp.NewRequest(
-			d.config.Method.String(), d.config.Src, nil,
+		req, err = retryablehttp.NewRequestWithContext(
+			ctx, valueOf(d.config.Method).String(), src, nil,
 		)
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	// Add query params if provided
 	if d.config.UrlQueryParams != nil {
@@ -110,26 +489,381 @@ func (d *Downloader) Download() (err error) {
 	}
 
 	// Set headers
-	req.Header = d.config.Headers
-	// Add headers to redirects
-	client.HTTPClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		for key, val := range via[0].Header {
-			req.Header[key] = val
+	if d.config.Headers != nil {
+		req.Header = d.config.Headers.Clone()
+	}
+	d.authenticate(req.Request)
+
+	// Resumable downloads are written to a well known partial file
+	// which is locked while in use. A concurrent download of the same
+	// destination is written to a temporary file instead, so it can
+	// neither resume nor clobber the partial file or its resume state.
+	var part *atomicFile
+	if d.resumable() {
+		part, err = openPartialFile(d.config.Dest, d.fileMode())
+		switch {
+		case errors.Is(err, errPartialLocked):
+			logger.Debug("partial download is in use, not resuming", "dest", d.config.Dest)
+		case err != nil:
+			return nil, err
+		default:
+			defer part.Abort()
+		}
+	}
+
+	var offset int64
+	if part != nil && valueOf(d.config.Method) == GET {
+		offset = d.prepareResume(req, src, part)
+	}
+
+	// Revalidate a cached copy instead of downloading it again
+	c := d.cache()
+	var cached *cacheEntry
+	if c != nil && offset == 0 && valueOf(d.config.Method) == GET {
+		if cached = c.Lookup(req.URL.String()); cached != nil {
+			cached.SetConditional(req.Header)
+		}
+	}
+
+	// Large downloads may be split into concurrent range requests
+	if valueOf(d.config.Segments) > 1 && !valueOf(d.config.Decompress) && valueOf(d.config.Method) == GET && offset == 0 && cached == nil {
+		var ok bool
+		if result, ok, err = d.fetchSegmented(ctx, client, req, sum, ui, logger); ok || err != nil {
+			return result, err
 		}
-		return err
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
+	defer func() { resp.Body.Close() }()
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		logger.Debug("using cached download", "url", redactURL(req.URL))
+		if result, err = d.fromCache(c, cached, resp, sum); err == nil && part != nil {
+			// The cached copy replaces any partial download
+			removeResumeState(d.config.Dest)
+			part.Discard()
+		}
+		return result, err
+	}
+
+	var complete bool
+	if offset > 0 {
+		if complete, err = d.checkResume(resp, offset); err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusRequestedRangeNotSatisfiable:
+			if complete {
+				break
+			}
+			// The partial file cannot be continued so start over
+			// with a full download
+			resp.Body.Close()
+			req.Header.Del("Range")
+			req.Header.Del("If-Range")
+			if resp, err = client.Do(req); err != nil {
+				return nil, err
+			}
+			offset = 0
+		default:
+			offset = 0
+		}
+	}
+
+	if !complete {
+		if err = d.checkStatus(valueOf(d.config.Method), resp); err != nil {
+			return nil, err
+		}
+	}
+
+	// When resuming is enabled and the download is starting over,
+	// record the validators so an interruption can be continued
+	if part != nil && offset == 0 {
+		if err = newResumeState(src, resp).save(d.config.Dest); err != nil {
+			return nil, err
+		}
+	}
+
+	// Write to a temporary file which is moved into place once the
+	// download is complete. Resumable downloads use the partial file
+	// so they can be continued after an interruption.
+	out := part
+	if out == nil {
+		if out, err = newAtomicFile(d.config.Dest, d.fileMode()); err != nil {
+			return nil, err
+		}
+	} else if offset == 0 {
+		if err = out.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+
+	// Data from a previous attempt must be included in the digest
+	if sum != nil && offset > 0 {
+		if err = sum.ReadFile(out.Name()); err != nil {
+			out.Abort()
+			return nil, err
+		}
+	}
+
+	// Stream the response body directly to the file so memory
+	// usage stays bounded regardless of the download size
+	if !complete {
+		w, finish := d.destWriter(out, sum, resp.Header)
+		writers := []io.Writer{w}
+		if ui != nil {
+			total := int64(-1)
+			if resp.ContentLength >= 0 {
+				total = offset + resp.ContentLength
+			}
+			prog := newProgress(ui, path.Base(resp.Request.URL.Path), offset, total)
+			defer func() { prog.Finish(err) }()
+			writers = append(writers, prog)
+		}
+		_, err = io.Copy(io.MultiWriter(writers...), resp.Body)
+		if err = finish(err); err != nil {
+			out.Abort()
+			// Report cancellation rather than the resulting read error
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		}
+	}
+
+	// Remove the file on mismatch so a corrupted download is
+	// never moved into place or resumed
+	if sum != nil {
+		if err = sum.Verify(); err != nil {
+			if part != nil {
+				removeResumeState(d.config.Dest)
+			}
+			out.Discard()
+			return nil, err
+		}
+	}
+
+	// The resume state is removed while the partial file is
+	// still locked, before it is moved into place
+	if part != nil {
+		removeResumeState(d.config.Dest)
+	}
+	if err = out.Commit(); err != nil {
+		out.Discard()
+		return nil, err
+	}
+
+	if c != nil && valueOf(d.config.Method) == GET {
+		if err := c.Store(req.URL.String(), resp, d.config.Dest); err != nil {
+			logger.Warn("failed to store download in cache", "error", err)
+		}
+	}
+
+	return newResult(resp.Request.URL, resp, sum), nil
+}
+
+// fromCache writes the cached copy of a download to the destination
+func (d *Downloader) fromCache(
+	c *cache,
+	e *cacheEntry,
+	resp *http.Response,
+	sum *checksum,
+) (*DownloadResult, error) {
+	in, err := c.Open(e)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = os.WriteFile(d.config.Dest, data, 0644)
-	return
+	defer in.Close()
+
+	out, err := newAtomicFile(d.config.Dest, d.fileMode())
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = out
+	if sum != nil {
+		w = io.MultiWriter(out, sum)
+	}
+	if _, err = io.Copy(w, in); err != nil {
+		out.Abort()
+		return nil, err
+	}
+
+	// A cached copy which does not match is removed so
+	// it is downloaded again on the next attempt
+	if sum != nil {
+		if err = sum.Verify(); err != nil {
+			out.Discard()
+			c.Remove(e)
+			return nil, err
+		}
+	}
+
+	if err = out.Commit(); err != nil {
+		out.Discard()
+		return nil, err
+	}
+
+	result := newResult(resp.Request.URL, resp, sum)
+	result.Cached = true
+	return result, nil
+}
+
+// fileMode returns the mode of the downloaded file
+func (d *Downloader) fileMode() os.FileMode {
+	if valueOf(d.config.FileMode) == 0 {
+		return 0644
+	}
+	return valueOf(d.config.FileMode)
+}
+
+// checkStatus returns a StatusError if the response status code
+// is not accepted for the given request method
+func (d *Downloader) checkStatus(method HTTPMethod, resp *http.Response) error {
+	if codes, ok := d.config.AcceptedStatusCodes[method]; ok {
+		for _, c := range codes {
+			if resp.StatusCode == c {
+				return nil
+			}
+		}
+	} else if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		URL:        redactURL(resp.Request.URL),
+		Body:       strings.TrimSpace(strings.ToValidUTF8(string(excerpt), "")),
+	}
+}
+
+// expectedChecksum returns the checksum used to validate the download,
+// fetching it from the checksum file if required. If no checksum is
+// configured a checksum which only computes the digest is returned.
+func (d *Downloader) expectedChecksum(ctx context.Context, client *retryablehttp.Client) (*checksum, error) {
+	kind, value := d.config.ChecksumType, d.config.Checksum
+	if value == "" && d.config.ChecksumURL != "" {
+		body, err := d.openChecksumFile(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+
+		src, err := parseSource(d.config.Src)
+		if err != nil {
+			return nil, err
+		}
+		k, v, err := parseChecksumFile(body, src.Path)
+		if err != nil {
+			return nil, err
+		}
+		if k != "" {
+			kind = k
+		}
+		value = v
+	}
+	// The digest is still computed so it can be included
+	// in the result of the download
+	if value == "" {
+		return newDigest(), nil
+	}
+
+	return newChecksum(kind, value)
+}
+
+// openChecksumFile opens the checksum file located at ChecksumURL
+func (d *Downloader) openChecksumFile(ctx context.Context, client *retryablehttp.Client) (io.ReadCloser, error) {
+	u, err := parseSource(d.config.ChecksumURL)
+	if err != nil {
+		return nil, err
+	}
+	if !isHTTP(u) {
+		h, ok := lookupScheme(u.Scheme)
+		if !ok {
+			return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+		}
+		body, _, err := h.Open(ctx, u)
+		return body, err
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if d.config.Headers != nil {
+		req.Header = d.config.Headers.Clone()
+	}
+	d.authenticate(req.Request)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkStatus(GET, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// resumable returns if interrupted downloads can be resumed.
+// Decompressed downloads cannot be resumed as the partial file
+// does not contain the downloaded data.
+func (d *Downloader) resumable() bool {
+	return valueOf(d.config.Resume) && !valueOf(d.config.Decompress)
+}
+
+// prepareResume checks if the locked partial file is a download of
+// the source. If it is, the request is updated to only fetch the
+// remaining bytes and the current size of the partial file is returned.
+func (d *Downloader) prepareResume(req *retryablehttp.Request, src string, part *atomicFile) int64 {
+	info, err := part.Stat()
+	if err != nil || info.Size() == 0 {
+		return 0
+	}
+	state := loadResumeState(d.config.Dest)
+	if state == nil || state.Src != src {
+		return 0
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+	if v := state.ifRange(); v != "" {
+		req.Header.Set("If-Range", v)
+	}
+
+	return info.Size()
+}
+
+// checkResume validates the response to a ranged request. It returns
+// true if the partial file was already complete. Servers which do not
+// support ranges, or whose resource has changed, respond with the full
+// content which is handled as a new download.
+func (d *Downloader) checkResume(resp *http.Response, offset int64) (complete bool, err error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, _, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return false, err
+		}
+		if start != offset {
+			return false, fmt.Errorf(
+				"server resumed download at byte %d, expected byte %d", start, offset)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// If the partial file is the same size as the resource then
+		// it was completely downloaded before being interrupted
+		_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && total == offset {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
 
 var (
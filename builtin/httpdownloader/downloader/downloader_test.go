@@ -0,0 +1,185 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testContent is large enough to be downloaded in 4 segments
+var testContent = bytes.Repeat([]byte("0123456789abcdef"), 4*minSegmentSize/16)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testServer serves the content with support for range and
+// conditional requests, recording the requests it receives
+type testServer struct {
+	*httptest.Server
+
+	m        sync.Mutex
+	etag     string
+	ranges   bool
+	requests []*http.Request
+	statuses []int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{etag: `"v1"`, ranges: true}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.m.Lock()
+		s.requests = append(s.requests, r)
+		etag, ranges := s.etag, s.ranges
+		s.m.Unlock()
+
+		if !ranges {
+			w.Write(testContent)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		rec := &statusRecorder{ResponseWriter: w, server: s}
+		http.ServeContent(rec, r, "box", time.Unix(1500000000, 0), bytes.NewReader(testContent))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) reset() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.requests = nil
+	s.statuses = nil
+}
+
+// rangeRequests returns the Range headers of the requests
+func (s *testServer) rangeRequests() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var ranges []string
+	for _, r := range s.requests {
+		if v := r.Header.Get("Range"); v != "" {
+			ranges = append(ranges, v)
+		}
+	}
+	return ranges
+}
+
+// statusCodes returns the status codes of the responses
+func (s *testServer) statusCodes() []int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]int(nil), s.statuses...)
+}
+
+// statusRecorder records the status of the response before it is sent
+type statusRecorder struct {
+	http.ResponseWriter
+	server *testServer
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.server.m.Lock()
+	r.server.statuses = append(r.server.statuses, status)
+	r.server.m.Unlock()
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func checkContent(t *testing.T, p string) {
+	t.Helper()
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, testContent) {
+		t.Fatalf("expected %d bytes of content, got %d bytes", len(testContent), len(b))
+	}
+}
+
+func TestDownloadStatusError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such box", http.StatusNotFound)
+	}))
+	t.Cleanup(s.Close)
+
+	u := strings.Replace(s.URL, "://", "://user:hunter2@", 1)
+	d := &Downloader{config: DownloaderConfig{
+		Src:  u,
+		Dest: filepath.Join(t.TempDir(), "box"),
+	}}
+	_, err := d.Download(DownloadInput{})
+	var serr *StatusError
+	if !errors.As(err, &serr) {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if serr.StatusCode != http.StatusNotFound || serr.Body != "no such box" {
+		t.Fatalf("unexpected status error %#v", serr)
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected the password to be removed from %q", err)
+	}
+	if _, err = os.Stat(d.config.Dest); !os.IsNotExist(err) {
+		t.Fatalf("expected no file at the destination, got %v", err)
+	}
+
+	// Accepted status codes are written to the destination
+	d.config.AcceptedStatusCodes = map[HTTPMethod][]int{GET: {http.StatusNotFound}}
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", result.StatusCode)
+	}
+}
+
+func TestDownloadMirrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	corrupt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testContent[1:])
+	}))
+	t.Cleanup(corrupt.Close)
+	s := newTestServer(t)
+
+	sum := sha256.Sum256(testContent)
+	d := &Downloader{config: DownloaderConfig{
+		Src:        failing.URL,
+		Mirrors:    []string{corrupt.URL, s.URL + "/box"},
+		Dest:       filepath.Join(t.TempDir(), "box"),
+		Checksum:   hex.EncodeToString(sum[:]),
+		RetryCount: ptr(0),
+	}}
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if result.Source != s.URL+"/box" {
+		t.Fatalf("expected the last mirror to be used, got %s", result.Source)
+	}
+
+	// The errors of every source are returned
+	d.config.Mirrors = d.config.Mirrors[:1]
+	_, err = d.Download(DownloadInput{})
+	var serr *StatusError
+	var cerr *ChecksumError
+	if !errors.As(err, &serr) || !errors.As(err, &cerr) {
+		t.Fatalf("expected the errors of both sources, got %v", err)
+	}
+}
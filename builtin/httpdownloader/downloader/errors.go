@@ -0,0 +1,75 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChecksumError is returned when the checksum of a downloaded
+// file does not match the expected value
+type ChecksumError struct {
+	Type     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s",
+		e.Type, e.Expected, e.Actual)
+}
+
+// ChecksumTypeError is returned when an unsupported checksum
+// type is requested
+type ChecksumTypeError struct {
+	Type string
+}
+
+func (e *ChecksumTypeError) Error() string {
+	return fmt.Sprintf("unsupported checksum type %q (supported types: %s)",
+		e.Type, strings.Join(supportedChecksumTypes(), ", "))
+}
+
+// StatusError is returned when the server responds with a
+// status code which is not accepted for the request method
+type StatusError struct {
+	StatusCode int
+	Status     string
+	URL        string
+	// Body is a truncated excerpt of the response body
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("unexpected response from %s: %s", e.URL, e.Status)
+	if e.Body != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Body)
+	}
+	return msg
+}
+
+// RedirectError is returned when a redirect is refused
+// by the redirect policy
+type RedirectError struct {
+	URL    string
+	Reason string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("redirect to %s refused: %s", e.URL, e.Reason)
+}
+
+// ConfigError is returned when a field of the downloader
+// or uploader configuration is invalid
+type ConfigError struct {
+	// Namespace is the config namespace of the field
+	Namespace string
+	Field     string
+	Reason    string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s configuration: %s %s", e.Namespace, e.Field, e.Reason)
+}
@@ -13,11 +13,13 @@ func _() {
 	_ = x[HEAD-2]
 	_ = x[POST-3]
 	_ = x[PUT-4]
+	_ = x[PATCH-5]
+	_ = x[OPTIONS-6]
 }
 
-const _HTTPMethod_name = "GETDELETEHEADPOSTPUT"
+const _HTTPMethod_name = "GETDELETEHEADPOSTPUTPATCHOPTIONS"
 
-var _HTTPMethod_index = [...]uint8{0, 3, 9, 13, 17, 20}
+var _HTTPMethod_index = [...]uint8{0, 3, 9, 13, 17, 20, 25, 32}
 
 func (i HTTPMethod) String() string {
 	if i < 0 || i >= HTTPMethod(len(_HTTPMethod_index)-1) {
@@ -0,0 +1,25 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build !windows
+
+package downloader
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// moveOpenFiles is true as open files can be renamed and removed
+const moveOpenFiles = true
+
+// lockFile takes an exclusive advisory lock on the file without
+// waiting. The lock is released when the file is closed.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errPartialLocked
+	}
+	return err
+}
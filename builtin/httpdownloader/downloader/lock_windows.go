@@ -0,0 +1,31 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build windows
+
+package downloader
+
+import (
+	"errors"
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// moveOpenFiles is false as open files cannot be renamed or removed
+const moveOpenFiles = false
+
+// lockFile takes an exclusive lock on the file without waiting. The
+// lock is released when the file is closed. Locks are mandatory on
+// Windows, so a byte past any content is locked which leaves the
+// content readable for computing the checksum.
+func lockFile(f *os.File) error {
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0, 1, 0, &windows.Overlapped{Offset: math.MaxUint32, OffsetHigh: math.MaxInt32})
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errPartialLocked
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vagrant-plugin-sdk/component"
+	"github.com/mitchellh/mapstructure"
+)
+
+// ConfigDataToDownloaderConfig decodes the configuration of the
+// downloader namespace. Names may be given in snake case, such as
+// "retry_count". HTTP methods are decoded from their names, such as
+// "post", and durations from strings such as "30s".
+func ConfigDataToDownloaderConfig(in *component.ConfigData) (*DownloaderConfig, error) {
+	var result DownloaderConfig
+	return &result, decodeConfig(in, &result)
+}
+
+// ConfigDataToUploaderConfig decodes the configuration of the
+// uploader namespace like ConfigDataToDownloaderConfig
+func ConfigDataToUploaderConfig(in *component.ConfigData) (*UploaderConfig, error) {
+	var result UploaderConfig
+	return &result, decodeConfig(in, &result)
+}
+
+// decodeConfig decodes the data of the configuration into the
+// result. Values implementing encoding.TextUnmarshaler are decoded
+// from strings, which mapstructure does not do without a hook.
+func decodeConfig(in *component.ConfigData, result any) error {
+	if in == nil {
+		return nil
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.TextUnmarshallerHookFunc(),
+			mapstructure.StringToTimeDurationHookFunc(),
+		),
+		MatchName: func(key, field string) bool {
+			return strings.EqualFold(strings.ReplaceAll(key, "_", ""), field)
+		},
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(in.Data)
+}
@@ -0,0 +1,52 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vagrant-plugin-sdk/component"
+)
+
+func TestConfigDataToDownloaderConfig(t *testing.T) {
+	conf, err := ConfigDataToDownloaderConfig(&component.ConfigData{
+		Data: map[string]interface{}{
+			"method":  "post",
+			"timeout": "30s",
+			"resume":  false,
+			"accepted_status_codes": map[string]interface{}{
+				"head": []interface{}{200, 404},
+			},
+			"retry_count": 0,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Method == nil || *conf.Method != POST {
+		t.Fatalf("expected method POST, got %v", conf.Method)
+	}
+	if conf.Timeout == nil || *conf.Timeout != 30*time.Second {
+		t.Fatalf("expected timeout of 30s, got %v", conf.Timeout)
+	}
+	if conf.Resume == nil || *conf.Resume {
+		t.Fatalf("expected resume to be set to false, got %v", conf.Resume)
+	}
+	if conf.RetryCount == nil || *conf.RetryCount != 0 {
+		t.Fatalf("expected retry count to be set to 0, got %v", conf.RetryCount)
+	}
+	if codes := conf.AcceptedStatusCodes[HEAD]; len(codes) != 2 {
+		t.Fatalf("expected accepted status codes for HEAD, got %v", conf.AcceptedStatusCodes)
+	}
+}
+
+func TestConfigDataToUploaderConfigInvalidMethod(t *testing.T) {
+	_, err := ConfigDataToUploaderConfig(&component.ConfigData{
+		Data: map[string]interface{}{"method": "fetch"},
+	})
+	if err == nil {
+		t.Fatal("expected error decoding an unknown method")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseHTTPMethod returns the HTTPMethod for the name. The
+// name is case insensitive.
+func ParseHTTPMethod(name string) (HTTPMethod, error) {
+	n := strings.ToUpper(strings.TrimSpace(name))
+	for m := HTTPMethod(0); m.valid(); m++ {
+		if m.String() == n {
+			return m, nil
+		}
+	}
+
+	return GET, fmt.Errorf("unknown HTTP method %q (supported methods: %s)",
+		name, strings.Join(httpMethodNames(), ", "))
+}
+
+// httpMethodNames returns the names of all the HTTP methods
+func httpMethodNames() []string {
+	var names []string
+	for m := HTTPMethod(0); m.valid(); m++ {
+		names = append(names, m.String())
+	}
+	return names
+}
+
+// valid returns if the method is a known HTTP method
+func (m HTTPMethod) valid() bool {
+	return m >= 0 && m < HTTPMethod(len(_HTTPMethod_index)-1)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (m HTTPMethod) MarshalText() ([]byte, error) {
+	if !m.valid() {
+		return nil, fmt.Errorf("unknown HTTP method %s", m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *HTTPMethod) UnmarshalText(text []byte) error {
+	v, err := ParseHTTPMethod(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The method may be
+// given by name or, for compatibility, by its numeric value.
+func (m *HTTPMethod) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		return m.UnmarshalText([]byte(name))
+	}
+
+	var v int64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("HTTP method must be a string: %s", data)
+	}
+	if !HTTPMethod(v).valid() {
+		return fmt.Errorf("unknown HTTP method %s", HTTPMethod(v))
+	}
+	*m = HTTPMethod(v)
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+)
+
+const (
+	// progressBarWidth is the number of characters used to draw
+	// the progress bar
+	progressBarWidth = 30
+	// progressInterval is how often an interactive progress
+	// bar is updated
+	progressInterval = 250 * time.Millisecond
+	// progressLogInterval is how often progress is output
+	// when the UI is not interactive
+	progressLogInterval = 10 * time.Second
+)
+
+// progress reports the progress of a download or upload to the
+// UI. It is an io.Writer which counts the bytes written to it.
+type progress struct {
+	ui     terminal.UI
+	status terminal.Status
+	name   string
+	// verb describes the transfer, like "Download"
+	verb string
+
+	// offset is the number of bytes which were already downloaded
+	// when the progress started and is excluded from the rate
+	offset  int64
+	total   int64
+	current atomic.Int64
+	start   time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newProgress creates a new progress for the named download. The
+// total is the expected size of the download, or -1 if unknown.
+func newProgress(ui terminal.UI, name string, offset, total int64) *progress {
+	return startProgress(ui, "Download", name, offset, total)
+}
+
+// newUploadProgress creates a new progress for the named upload
+func newUploadProgress(ui terminal.UI, name string, total int64) *progress {
+	return startProgress(ui, "Upload", name, 0, total)
+}
+
+func startProgress(ui terminal.UI, verb, name string, offset, total int64) *progress {
+	p := &progress{
+		ui:     ui,
+		name:   name,
+		verb:   verb,
+		offset: offset,
+		total:  total,
+		start:  time.Now(),
+		stop:   make(chan struct{}),
+	}
+	p.current.Store(offset)
+
+	interval := progressLogInterval
+	if ui.Interactive() && !ui.MachineReadable() {
+		p.status = ui.Status()
+		interval = progressInterval
+	}
+
+	p.wg.Add(1)
+	go p.run(interval)
+
+	return p
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.current.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Restart resets the progress to the initial offset, such as
+// when a transfer is attempted again from the beginning
+func (p *progress) Restart() {
+	p.current.Store(p.offset)
+}
+
+// Finish stops reporting progress and outputs the final
+// result of the download
+func (p *progress) Finish(err error) {
+	close(p.stop)
+	p.wg.Wait()
+
+	elapsed := time.Since(p.start).Round(time.Second)
+	if err != nil {
+		msg := fmt.Sprintf("%s of %s failed after %s", p.verb, p.name, elapsed)
+		if p.status != nil {
+			p.status.Step(terminal.StatusError, msg)
+			p.status.Close()
+		} else {
+			p.ui.Output("%s", msg, terminal.WithErrorStyle())
+		}
+		return
+	}
+
+	msg := fmt.Sprintf("%sed %s (%s in %s)",
+		p.verb, p.name, formatBytes(p.current.Load()), elapsed)
+	if p.status != nil {
+		p.status.Step(terminal.StatusOk, msg)
+		p.status.Close()
+	} else {
+		p.ui.Output("%s", msg, terminal.WithSuccessStyle())
+	}
+}
+
+func (p *progress) run(interval time.Duration) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if p.status != nil {
+				p.status.Update(p.String())
+			} else {
+				p.ui.Output("%s", p.String(), terminal.WithInfoStyle())
+			}
+		}
+	}
+}
+
+// String returns a description of the current progress
+// including the transfer rate and estimated time remaining
+func (p *progress) String() string {
+	current := p.current.Load()
+	elapsed := time.Since(p.start)
+
+	var rate float64
+	if s := elapsed.Seconds(); s > 0 {
+		rate = float64(current-p.offset) / s
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%sing %s: ", p.verb, p.name)
+	if p.total <= 0 {
+		fmt.Fprintf(&b, "%s, %s/s", formatBytes(current), formatBytes(int64(rate)))
+		return b.String()
+	}
+
+	pct := float64(current) / float64(p.total)
+	if pct > 1 {
+		pct = 1
+	}
+	if p.status != nil {
+		filled := int(pct * progressBarWidth)
+		fmt.Fprintf(&b, "[%s%s] ",
+			strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled))
+	}
+	fmt.Fprintf(&b, "%d%% %s / %s, %s/s", int(pct*100),
+		formatBytes(current), formatBytes(p.total), formatBytes(int64(rate)))
+	if rate > 0 {
+		eta := time.Duration(float64(p.total-current) / rate * float64(time.Second))
+		fmt.Fprintf(&b, ", ETA %s", eta.Round(time.Second))
+	}
+
+	return b.String()
+}
+
+// formatBytes returns a human readable representation
+// of the given number of bytes
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
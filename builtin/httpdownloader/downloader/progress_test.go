@@ -0,0 +1,61 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+)
+
+// testUI records the output of a UI which is not interactive
+type testUI struct {
+	terminal.UI
+
+	m     sync.Mutex
+	lines []string
+}
+
+func (u *testUI) Output(msg string, raw ...interface{}) {
+	var args []interface{}
+	for _, v := range raw {
+		if _, ok := v.(terminal.Option); !ok {
+			args = append(args, v)
+		}
+	}
+
+	u.m.Lock()
+	defer u.m.Unlock()
+	u.lines = append(u.lines, fmt.Sprintf(msg, args...))
+}
+
+func (u *testUI) Interactive() bool     { return false }
+func (u *testUI) MachineReadable() bool { return false }
+
+func (u *testUI) output() []string {
+	u.m.Lock()
+	defer u.m.Unlock()
+	return append([]string(nil), u.lines...)
+}
+
+func TestDownloadProgress(t *testing.T) {
+	s := newTestServer(t)
+	d := &Downloader{config: DownloaderConfig{
+		Src:  s.URL + "/100%25.box",
+		Dest: filepath.Join(t.TempDir(), "box"),
+	}}
+
+	ui := &testUI{}
+	if _, err := d.Download(DownloadInput{UI: ui}); err != nil {
+		t.Fatal(err)
+	}
+	lines := ui.output()
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "Downloaded 100%.box (4.0 MiB in ") {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedTransport limits the rate at which response bodies
+// are read. All requests made through the transport share the same
+// limit, so it applies to the combined rate of segmented downloads.
+type rateLimitedTransport struct {
+	http.RoundTripper
+
+	limiter *rate.Limiter
+}
+
+// rateLimit wraps the transport with the configured rate limit. If
+// no rate limit is configured the transport is returned unchanged.
+func (d *Downloader) rateLimit(t http.RoundTripper) http.RoundTripper {
+	if valueOf(d.config.RateLimit) <= 0 {
+		return t
+	}
+	burst := valueOf(d.config.RateLimitBurst)
+	if burst <= 0 {
+		burst = int(valueOf(d.config.RateLimit))
+	}
+	return &rateLimitedTransport{
+		RoundTripper: t,
+		limiter:      rate.NewLimiter(rate.Limit(valueOf(d.config.RateLimit)), burst),
+	}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = &rateLimitedBody{
+		ReadCloser: resp.Body,
+		ctx:        req.Context(),
+		limiter:    t.limiter,
+	}
+	return resp, nil
+}
+
+// rateLimitedBody is a response body which waits for the
+// limiter after each read
+type rateLimitedBody struct {
+	io.ReadCloser
+
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (b *rateLimitedBody) Read(p []byte) (int, error) {
+	// Reads cannot be larger than the burst or they
+	// would never be permitted by the limiter
+	if burst := b.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.limiter.WaitN(b.ctx, n); werr != nil {
+			// The limiter fails without waiting when the wait would
+			// exceed the deadline, which is reported as the deadline
+			// being exceeded so it is handled like a timeout
+			if b.ctx.Err() == nil {
+				werr = context.DeadlineExceeded
+			}
+			return n, werr
+		}
+	}
+	return n, err
+}
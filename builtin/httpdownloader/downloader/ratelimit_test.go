@@ -0,0 +1,64 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadRateLimit(t *testing.T) {
+	content := testContent[:512<<10]
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	t.Cleanup(s.Close)
+
+	// The first burst is read immediately and the
+	// remaining 384 KiB take at least 375ms
+	d := &Downloader{config: DownloaderConfig{
+		Src:            s.URL,
+		Dest:           filepath.Join(t.TempDir(), "box"),
+		RateLimit:      ptr[int64](1 << 20),
+		RateLimitBurst: ptr(128 << 10),
+	}}
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Size != int64(len(content)) {
+		t.Fatalf("expected %d bytes, got %d", len(content), result.Size)
+	}
+	if result.Elapsed < 375*time.Millisecond {
+		t.Fatalf("expected the download to be rate limited, took %s", result.Elapsed)
+	}
+}
+
+func TestDownloadRateLimitTimeout(t *testing.T) {
+	s := newTestServer(t)
+	d := &Downloader{config: DownloaderConfig{
+		Src:       s.URL,
+		Dest:      filepath.Join(t.TempDir(), "box"),
+		RateLimit: ptr[int64](1 << 10),
+		Timeout:   ptr(100 * time.Millisecond),
+	}}
+
+	start := time.Now()
+	_, err := d.Download(DownloadInput{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the download to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected waiting for the rate limit to stop, took %s", elapsed)
+	}
+	if _, err = os.Stat(d.config.Dest); !os.IsNotExist(err) {
+		t.Fatalf("expected no file at the destination, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"net/http"
+)
+
+// defaultMaxRedirects is the number of redirects followed
+// when no maximum is configured
+const defaultMaxRedirects = 10
+
+// sensitiveHeaders are the request headers which are removed
+// when a redirect leaves the original host
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"WWW-Authenticate",
+}
+
+// checkRedirect enforces the redirect policy. The number of redirects
+// is limited, redirects from HTTPS to HTTP are refused, and credentials
+// are removed when a redirect changes the host or scheme of the
+// original request.
+func (d *Downloader) checkRedirect(req *http.Request, via []*http.Request) error {
+	max := defaultMaxRedirects
+	if d.config.MaxRedirects != nil {
+		max = *d.config.MaxRedirects
+	}
+	if len(via) > max || max < 0 {
+		return &RedirectError{
+			URL:    redactURL(req.URL),
+			Reason: "too many redirects",
+		}
+	}
+
+	prev := via[len(via)-1]
+	if prev.URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return &RedirectError{
+			URL:    redactURL(req.URL),
+			Reason: "refusing to downgrade from https to " + req.URL.Scheme,
+		}
+	}
+
+	orig := via[0]
+	if req.URL.Host != orig.URL.Host || req.URL.Scheme != orig.URL.Scheme {
+		for _, h := range sensitiveHeaders {
+			req.Header.Del(h)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// redirectServer redirects requests for /redirect to the
+// target and records the Authorization header of the others
+func redirectServer(t *testing.T, target func() string, auth *string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, target(), http.StatusFound)
+			return
+		}
+		*auth = r.Header.Get("Authorization")
+		w.Write(testContent)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestDownloadRedirectCredentials(t *testing.T) {
+	var sameAuth, otherAuth string
+	other := redirectServer(t, nil, &otherAuth)
+	var same *httptest.Server
+	same = redirectServer(t, func() string { return same.URL + "/box" }, &sameAuth)
+	cross := redirectServer(t, func() string { return other.URL + "/box" }, new(string))
+
+	for _, tc := range []struct {
+		name string
+		src  string
+		auth *string
+		want string
+	}{
+		{name: "same host", src: same.URL + "/redirect", auth: &sameAuth, want: "Bearer token"},
+		{name: "other host", src: cross.URL + "/redirect", auth: &otherAuth},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Downloader{config: DownloaderConfig{
+				Src:         tc.src,
+				Dest:        filepath.Join(t.TempDir(), "box"),
+				BearerToken: "token",
+			}}
+			result, err := d.Download(DownloadInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkContent(t, d.config.Dest)
+			if *tc.auth != tc.want {
+				t.Fatalf("expected authorization %q, got %q", tc.want, *tc.auth)
+			}
+			if result.URL == tc.src {
+				t.Fatalf("expected the result to have the redirected URL, got %s", result.URL)
+			}
+		})
+	}
+}
+
+func TestDownloadRedirectRefused(t *testing.T) {
+	plain := redirectServer(t, func() string { return "/box" }, new(string))
+	var loop *httptest.Server
+	loop = redirectServer(t, func() string { return loop.URL + "/redirect" }, new(string))
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/box", http.StatusFound)
+	}))
+	t.Cleanup(secure.Close)
+
+	for _, tc := range []struct {
+		name   string
+		config DownloaderConfig
+	}{
+		{name: "too many", config: DownloaderConfig{Src: loop.URL + "/redirect", MaxRedirects: ptr(3)}},
+		{name: "disabled", config: DownloaderConfig{Src: plain.URL + "/redirect", MaxRedirects: ptr(0)}},
+		{name: "downgrade", config: DownloaderConfig{Src: secure.URL, Insecure: ptr(true)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Downloader{config: tc.config}
+			d.config.Dest = filepath.Join(t.TempDir(), "box")
+			_, err := d.Download(DownloadInput{})
+			var rerr *RedirectError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("expected redirect error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resumeState is persisted next to a partial download so an interrupted
+// download can later be continued from where it stopped. The validators
+// are sent back to the server with If-Range so that a resource which has
+// changed in the meantime is downloaded again in full.
+type resumeState struct {
+	Src          string `json:"src"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// resumeStatePath returns the path of the state file for the
+// given download destination.
+func resumeStatePath(dest string) string {
+	return dest + ".resume"
+}
+
+// newResumeState builds the resume state for a response
+func newResumeState(src string, resp *http.Response) *resumeState {
+	return &resumeState{
+		Src:          src,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+}
+
+// loadResumeState reads the resume state for the given destination. If
+// no state exists, or it cannot be read, nil is returned.
+func loadResumeState(dest string) *resumeState {
+	data, err := os.ReadFile(resumeStatePath(dest))
+	if err != nil {
+		return nil
+	}
+	var r resumeState
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil
+	}
+	return &r
+}
+
+// save writes the resume state for the given destination
+func (r *resumeState) save(dest string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(resumeStatePath(dest), data, 0644)
+}
+
+// ifRange returns the validator to send in the If-Range header. Weak
+// entity tags are not permitted in If-Range, so the last modified time
+// is used instead when the entity tag is weak.
+func (r *resumeState) ifRange() string {
+	if r.ETag != "" && !strings.HasPrefix(r.ETag, "W/") {
+		return r.ETag
+	}
+	return r.LastModified
+}
+
+// removeResumeState deletes any resume state for the given destination
+func removeResumeState(dest string) {
+	os.Remove(resumeStatePath(dest))
+}
+
+// parseContentRange parses a Content-Range header value of the form
+// "bytes start-end/total" or "bytes */total". A total of -1 indicates
+// the complete length is unknown, and a start of -1 indicates an
+// unsatisfied range.
+func parseContentRange(v string) (start, end, total int64, err error) {
+	unit, spec, ok := strings.Cut(strings.TrimSpace(v), " ")
+	if !ok || unit != "bytes" {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+		}
+	}
+
+	if rng == "*" {
+		return -1, -1, total, nil
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+	if end, err = strconv.ParseInt(last, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", v)
+	}
+
+	return start, end, total, nil
+}
@@ -0,0 +1,158 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	s := newTestServer(t)
+	dest := filepath.Join(t.TempDir(), "box")
+	d := &Downloader{config: DownloaderConfig{
+		Src:    s.URL,
+		Dest:   dest,
+		Resume: ptr(true),
+	}}
+
+	// A partial download from an interrupted attempt
+	if err := os.WriteFile(partialPath(dest), testContent[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&resumeState{Src: s.URL, ETag: `"v1"`}).save(dest); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, dest)
+	if ranges := s.rangeRequests(); len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Fatalf("expected the download to resume, got ranges %q", ranges)
+	}
+	if result.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected partial content, got status %d", result.StatusCode)
+	}
+	if _, err := os.Stat(resumeStatePath(dest)); !os.IsNotExist(err) {
+		t.Fatalf("expected resume state to be removed, got %v", err)
+	}
+}
+
+func TestDownloadResumeChanged(t *testing.T) {
+	s := newTestServer(t)
+	dest := filepath.Join(t.TempDir(), "box")
+	d := &Downloader{config: DownloaderConfig{
+		Src:    s.URL,
+		Dest:   dest,
+		Resume: ptr(true),
+	}}
+
+	// The partial download is of a different version
+	if err := os.WriteFile(partialPath(dest), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&resumeState{Src: s.URL, ETag: `"v0"`}).save(dest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, dest)
+}
+
+func TestOpenPartialFileLocked(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "box")
+	part, err := openPartialFile(dest, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = openPartialFile(dest, 0644); !errors.Is(err, errPartialLocked) {
+		t.Fatalf("expected the partial file to be locked, got %v", err)
+	}
+
+	// The lock is released with the file
+	if _, err = part.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	part.Abort()
+	if part, err = openPartialFile(dest, 0644); err != nil {
+		t.Fatal(err)
+	}
+	part.Abort()
+}
+
+func TestDownloadResumeConcurrent(t *testing.T) {
+	// The first request stalls halfway through the
+	// body until the second download has completed
+	release := make(chan struct{})
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			t.Errorf("unexpected range request %q", r.Header.Get("Range"))
+		}
+		if requests.Add(1) > 1 {
+			w.Write(testContent)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(testContent)))
+		w.Write(testContent[:len(testContent)/2])
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write(testContent[len(testContent)/2:])
+	}))
+	t.Cleanup(s.Close)
+
+	dest := filepath.Join(t.TempDir(), "box")
+	newDownloader := func() *Downloader {
+		return &Downloader{config: DownloaderConfig{
+			Src:    s.URL,
+			Dest:   dest,
+			Resume: ptr(true),
+		}}
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := newDownloader().Download(DownloadInput{})
+		errs <- err
+	}()
+	for {
+		info, err := os.Stat(partialPath(dest))
+		if err == nil && info.Size() > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The partial file is in use so the second download
+	// is written to a temporary file instead
+	if _, err := newDownloader().Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, dest)
+	if info, err := os.Stat(partialPath(dest)); err != nil || info.Size() != int64(len(testContent)/2) {
+		t.Fatalf("expected the partial file to be kept, got %v", err)
+	}
+
+	close(release)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, dest)
+	for _, p := range []string{partialPath(dest), resumeStatePath(dest)} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// defaultRetryWaitMin is the minimum time to wait
+	// before retrying a request
+	defaultRetryWaitMin = 1 * time.Second
+	// defaultRetryWaitMax is the maximum time to wait
+	// before retrying a request
+	defaultRetryWaitMax = 30 * time.Second
+)
+
+// attemptsKey is the context key of the attempt counter
+// used to record the attempts of requests
+type attemptsKey struct{}
+
+// trackAttempts returns a context which records the highest
+// attempt of any request made with it in the returned counter
+func trackAttempts(ctx context.Context) (*atomic.Int64, context.Context) {
+	attempts := &atomic.Int64{}
+	return attempts, context.WithValue(ctx, attemptsKey{}, attempts)
+}
+
+// recordAttempt records the attempt of the request if the attempts
+// of its context are being tracked. When the context has a retry
+// budget the request is counted against it and the attempt is the
+// number of requests sent with the budget. The attempt is returned.
+func recordAttempt(req *http.Request, attempt int) int {
+	if b := budgetOf(req.Context()); b != nil {
+		attempt = int(b.sent.Add(1))
+	}
+	attempts, ok := req.Context().Value(attemptsKey{}).(*atomic.Int64)
+	if !ok {
+		return attempt
+	}
+	for {
+		current := attempts.Load()
+		if int64(attempt) <= current || attempts.CompareAndSwap(current, int64(attempt)) {
+			return attempt
+		}
+	}
+}
+
+// budgetKey is the context key of the retry budget
+type budgetKey struct{}
+
+// retryBudget counts the requests sent for a download, so the
+// retries made by the client and the retries of responses which
+// fail while they are read are limited by the same retry count
+type retryBudget struct {
+	sent atomic.Int64
+}
+
+// withRetryBudget returns a context whose requests
+// share the returned retry budget
+func withRetryBudget(ctx context.Context) (*retryBudget, context.Context) {
+	b := &retryBudget{}
+	return b, context.WithValue(ctx, budgetKey{}, b)
+}
+
+// budgetOf returns the retry budget of the context, or nil
+func budgetOf(ctx context.Context) *retryBudget {
+	b, _ := ctx.Value(budgetKey{}).(*retryBudget)
+	return b
+}
+
+// exhausted returns if the requests sent have used all retries
+func (b *retryBudget) exhausted(retries int) bool {
+	return b.sent.Load() > int64(max(retries, 0))
+}
+
+// configureRetry applies the retry policy to the client
+func (d *Downloader) configureRetry(client *retryablehttp.Client) {
+	client.RetryMax = valueOf(d.config.RetryCount)
+	client.RetryWaitMin = valueOf(d.config.RetryWaitMin)
+	if client.RetryWaitMin <= 0 {
+		client.RetryWaitMin = defaultRetryWaitMin
+	}
+	client.RetryWaitMax = valueOf(d.config.RetryWaitMax)
+	if client.RetryWaitMax <= 0 {
+		client.RetryWaitMax = defaultRetryWaitMax
+	}
+	if client.RetryWaitMax < client.RetryWaitMin {
+		client.RetryWaitMax = client.RetryWaitMin
+	}
+	client.CheckRetry = d.checkRetry
+	client.Backoff = d.backoff
+}
+
+// checkRetry determines if a request should be retried. Requests
+// are not retried once the context is done, when a redirect was
+// refused or when the retry budget of the context is exhausted. If
+// retryable status codes are configured only responses with those
+// codes are retried, otherwise the default policy of retrying 429
+// and 5xx responses, except 501, is used.
+func (d *Downloader) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	var rerr *RedirectError
+	if errors.As(err, &rerr) {
+		return false, err
+	}
+
+	if b := budgetOf(ctx); b != nil && b.exhausted(valueOf(d.config.RetryCount)) {
+		return false, nil
+	}
+
+	if err == nil && len(d.config.RetryStatusCodes) > 0 {
+		for _, c := range d.config.RetryStatusCodes {
+			if resp.StatusCode == c {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return retryablehttp.DefaultRetryPolicy(ctx, resp, err)
+}
+
+// backoff returns the time to wait before the next attempt. The
+// Retry-After header is honored for 429 and 503 responses, otherwise
+// the wait grows exponentially from min up to max. When jitter is
+// enabled a random wait between min and the computed value is used.
+func (d *Downloader) backoff(min, max time.Duration, attempt int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := retryAfter(resp); ok {
+			return wait
+		}
+	}
+
+	wait := max
+	if mult := math.Pow(2, float64(attempt)) * float64(min); mult < float64(max) {
+		wait = time.Duration(mult)
+	}
+	if valueOf(d.config.RetryJitter) && wait > min {
+		wait = min + time.Duration(rand.Int63n(int64(wait-min)))
+	}
+
+	return wait
+}
+
+// retryAfter parses the Retry-After header of the response which
+// may be given in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if wait := time.Until(t); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,246 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+)
+
+// SchemeHandler provides access to resources for a URL scheme
+// which is not handled by the HTTP client
+type SchemeHandler interface {
+	// Open returns a reader for the resource at the URL and the
+	// size of the resource, or -1 if the size is unknown
+	Open(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error)
+}
+
+// SchemeHandlerFunc is a function which implements SchemeHandler
+type SchemeHandlerFunc func(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error)
+
+func (f SchemeHandlerFunc) Open(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	return f(ctx, u)
+}
+
+var (
+	schemesLock sync.RWMutex
+	schemes     = map[string]SchemeHandler{
+		"file": SchemeHandlerFunc(openFile),
+	}
+)
+
+// RegisterScheme registers the handler for the URL scheme, replacing
+// any existing handler. The http and https schemes are always handled
+// by the HTTP client and cannot be registered.
+func RegisterScheme(scheme string, h SchemeHandler) error {
+	scheme = strings.ToLower(scheme)
+	if scheme == "http" || scheme == "https" {
+		return fmt.Errorf("cannot register handler for %s scheme", scheme)
+	}
+
+	schemesLock.Lock()
+	defer schemesLock.Unlock()
+	schemes[scheme] = h
+
+	return nil
+}
+
+// lookupScheme returns the handler for the URL scheme
+func lookupScheme(scheme string) (SchemeHandler, bool) {
+	schemesLock.RLock()
+	defer schemesLock.RUnlock()
+	h, ok := schemes[strings.ToLower(scheme)]
+	return h, ok
+}
+
+// isHTTP returns if the URL is handled by the HTTP client
+func isHTTP(u *url.URL) bool {
+	s := strings.ToLower(u.Scheme)
+	return s == "http" || s == "https"
+}
+
+// parseSource parses the source into a URL. Local paths, which
+// may not be valid URLs, are converted to file URLs.
+func parseSource(src string) (*url.URL, error) {
+	if filepath.IsAbs(src) || filepath.VolumeName(src) != "" {
+		return &url.URL{Scheme: "file", Path: filepath.ToSlash(src)}, nil
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" {
+		return u, nil
+	}
+
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}, nil
+}
+
+// localPath returns the local file path for a file URL
+func localPath(u *url.URL) (string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("remote file URLs are not supported: %s", redactURL(u))
+	}
+	p := u.Path
+	// Windows paths are given as /C:/path
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// openFile opens the local file for a file URL
+func openFile(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	p, err := localPath(u)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, 0, fmt.Errorf("%s is a directory", p)
+	}
+	return f, info.Size(), nil
+}
+
+// fetchScheme downloads the URL using the scheme handler and
+// writes it to the destination
+func (d *Downloader) fetchScheme(
+	ctx context.Context,
+	h SchemeHandler,
+	u *url.URL,
+	sum *checksum,
+	ui terminal.UI,
+) (result *DownloadResult, err error) {
+	if valueOf(d.config.Hardlink) && !valueOf(d.config.Decompress) && strings.EqualFold(u.Scheme, "file") {
+		if result, err = d.linkFile(u, sum); err == nil {
+			return result, nil
+		}
+		// Fall back to copying when the link cannot be created,
+		// such as when the file is on a different device
+		var cerr *ChecksumError
+		if errors.As(err, &cerr) {
+			return nil, err
+		}
+		if sum != nil {
+			sum.Reset()
+		}
+	}
+
+	in, size, err := h.Open(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out, err := newAtomicFile(d.config.Dest, d.fileMode())
+	if err != nil {
+		return nil, err
+	}
+
+	w, finish := d.destWriter(out, sum, nil)
+	writers := []io.Writer{w}
+	if ui != nil {
+		prog := newProgress(ui, path.Base(u.Path), 0, size)
+		defer func() { prog.Finish(err) }()
+		writers = append(writers, prog)
+	}
+	_, err = io.Copy(io.MultiWriter(writers...), &contextReader{ctx: ctx, r: in})
+	if err = finish(err); err != nil {
+		out.Abort()
+		return nil, err
+	}
+
+	if sum != nil {
+		if err = sum.Verify(); err != nil {
+			out.Discard()
+			return nil, err
+		}
+	}
+
+	if err = out.Commit(); err != nil {
+		out.Discard()
+		return nil, err
+	}
+
+	return newResult(u, nil, sum), nil
+}
+
+// linkFile creates a hard link to the local file at the destination.
+// The link is created next to the destination and then moved into
+// place. The mode of the file is not changed as it is shared with
+// the source.
+func (d *Downloader) linkFile(u *url.URL, sum *checksum) (*DownloadResult, error) {
+	p, err := localPath(u)
+	if err != nil {
+		return nil, err
+	}
+	// Linking avoids reading the file so the digest
+	// is only computed when it must be verified
+	if !sum.verifies() {
+		sum = nil
+	}
+	if sum != nil {
+		if err = sum.ReadFile(p); err != nil {
+			return nil, err
+		}
+		if err = sum.Verify(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Reserve a unique name for the link
+	tmp, err := newAtomicFile(d.config.Dest, d.fileMode())
+	if err != nil {
+		return nil, err
+	}
+	tmp.Discard()
+	if err = os.Link(p, tmp.Name()); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp.Name(), d.config.Dest); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return newResult(u, nil, sum), nil
+}
+
+// contextReader is a reader which stops reading
+// once the context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
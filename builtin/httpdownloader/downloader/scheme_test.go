@@ -0,0 +1,111 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSourceFile writes the content to a local file
+func testSourceFile(t *testing.T) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "source.box")
+	if err := os.WriteFile(p, testContent, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDownloadLocalFile(t *testing.T) {
+	src := testSourceFile(t)
+	sum := sha256.Sum256(testContent)
+
+	for _, tc := range []struct {
+		name     string
+		src      string
+		hardlink bool
+	}{
+		{name: "path", src: src},
+		{name: "file URL", src: (&url.URL{Scheme: "file", Path: filepath.ToSlash(src)}).String()},
+		{name: "hardlink", src: src, hardlink: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Downloader{config: DownloaderConfig{
+				Src:      tc.src,
+				Dest:     filepath.Join(t.TempDir(), "box"),
+				Checksum: hex.EncodeToString(sum[:]),
+				Hardlink: ptr(tc.hardlink),
+			}}
+			result, err := d.Download(DownloadInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkContent(t, d.config.Dest)
+			if result.StatusCode != 0 || result.Checksum != d.config.Checksum {
+				t.Fatalf("unexpected result %+v", result)
+			}
+
+			srcInfo, err := os.Stat(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(d.config.Dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if os.SameFile(srcInfo, info) != tc.hardlink {
+				t.Fatalf("expected the destination to be linked %t", tc.hardlink)
+			}
+			if !tc.hardlink && info.Mode().Perm() != 0644 {
+				t.Fatalf("expected the copy to have mode 0644, got %s", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestDownloadRegisteredScheme(t *testing.T) {
+	var opened string
+	err := RegisterScheme("Test-Scheme", SchemeHandlerFunc(func(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+		opened = u.Opaque + u.Path
+		return io.NopCloser(bytes.NewReader(testContent)), int64(len(testContent)), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{config: DownloaderConfig{
+		Src:  "test-scheme:boxes/box",
+		Dest: filepath.Join(t.TempDir(), "box"),
+	}}
+	if _, err = d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if opened != "boxes/box" {
+		t.Fatalf("expected the handler to open the source, got %q", opened)
+	}
+
+	d.config.Src = "unknown-scheme:box"
+	if _, err = d.Download(DownloadInput{}); err == nil || !strings.Contains(err.Error(), "unsupported URL scheme") {
+		t.Fatalf("expected unsupported scheme error, got %v", err)
+	}
+}
+
+func TestRegisterSchemeHTTP(t *testing.T) {
+	for _, scheme := range []string{"http", "HTTPS"} {
+		if err := RegisterScheme(scheme, SchemeHandlerFunc(openFile)); err == nil {
+			t.Errorf("expected registering %s to fail", scheme)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+)
+
+// minSegmentSize is the smallest range fetched by a segment. Smaller
+// resources are downloaded with fewer segments.
+const minSegmentSize = 1 << 20
+
+// errRangeMismatch is returned when the server responds to a segment
+// request with content other than the requested range
+var errRangeMismatch = errors.New("server did not return the requested range")
+
+// fetchSegmented downloads the resource using concurrent range
+// requests. The resource is first requested with HEAD to determine
+// its size and if ranges are supported. When ranges are not supported
+// false is returned and the resource should be downloaded with a
+// single request instead.
+func (d *Downloader) fetchSegmented(
+	ctx context.Context,
+	client *retryablehttp.Client,
+	req *retryablehttp.Request,
+	sum *checksum,
+	ui terminal.UI,
+	logger hclog.Logger,
+) (result *DownloadResult, ok bool, err error) {
+	// The probe has its own retry budget so it is not
+	// counted against the download of the resource
+	_, hctx := withRetryBudget(ctx)
+	head, err := retryablehttp.NewRequestWithContext(hctx, http.MethodHead, req.URL.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	head.Header = req.Header.Clone()
+	resp, err := client.Do(head)
+	if err != nil {
+		return nil, false, err
+	}
+	resp.Body.Close()
+
+	size := resp.ContentLength
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || size < 2*minSegmentSize {
+		logger.Debug("segmented download not supported, using a single stream",
+			"url", redactURL(req.URL), "status", resp.StatusCode, "size", size)
+		return nil, false, nil
+	}
+
+	count := int64(valueOf(d.config.Segments))
+	if max := size / minSegmentSize; count > max {
+		count = max
+	}
+
+	// Segments must all be fetched from the same version of the
+	// resource, so the validator is sent with every request
+	validator := newResumeState("", resp).ifRange()
+
+	out, err := newAtomicFile(d.config.Dest, d.fileMode())
+	if err != nil {
+		return nil, true, err
+	}
+	if err = out.Truncate(size); err != nil {
+		out.Abort()
+		return nil, true, err
+	}
+
+	var prog io.Writer = io.Discard
+	if ui != nil {
+		p := newProgress(ui, path.Base(resp.Request.URL.Path), 0, size)
+		defer func() { p.Finish(err) }()
+		prog = p
+	}
+
+	segCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var segErr error
+	segSize := size / count
+	for i := int64(0); i < count; i++ {
+		start, end := i*segSize, (i+1)*segSize-1
+		if i == count-1 {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.fetchSegment(segCtx, client, req, validator, out.File, start, end, prog); err != nil {
+				once.Do(func() {
+					segErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if segErr != nil {
+		out.Abort()
+		if ctx.Err() != nil {
+			return nil, true, ctx.Err()
+		}
+		return nil, true, segErr
+	}
+
+	// Segments complete out of order so the digest is computed
+	// once the file is assembled. It is computed even when it is
+	// not verified so it is included in the result like it is for
+	// downloads with a single request.
+	if sum != nil {
+		if err = sum.ReadFile(out.Name()); err != nil {
+			out.Abort()
+			return nil, true, err
+		}
+		if err = sum.Verify(); err != nil {
+			out.Discard()
+			return nil, true, err
+		}
+	}
+
+	if err = out.Commit(); err != nil {
+		out.Discard()
+		return nil, true, err
+	}
+
+	if c := d.cache(); c != nil {
+		if err := c.Store(req.URL.String(), resp, d.config.Dest); err != nil {
+			logger.Warn("failed to store download in cache", "error", err)
+		}
+	}
+
+	return newResult(resp.Request.URL, resp, sum), true, nil
+}
+
+// fetchSegment downloads the inclusive byte range of the resource
+// into the file. If reading the response fails the remainder of the
+// range is requested again. Each segment has its own retry budget
+// which is shared with the retries of the client.
+func (d *Downloader) fetchSegment(
+	ctx context.Context,
+	client *retryablehttp.Client,
+	base *retryablehttp.Request,
+	validator string,
+	f *os.File,
+	start, end int64,
+	prog io.Writer,
+) (err error) {
+	budget, ctx := withRetryBudget(ctx)
+	for {
+		var n int64
+		n, err = d.fetchRange(ctx, client, base, validator, f, start, end, prog)
+		start += n
+		if err == nil || ctx.Err() != nil || budget.exhausted(valueOf(d.config.RetryCount)) {
+			return err
+		}
+
+		// Only failures while reading the body are retried here
+		// as the client has already retried the request itself
+		var serr *StatusError
+		if n == 0 && (errors.As(err, &serr) || errors.Is(err, errRangeMismatch)) {
+			return err
+		}
+	}
+}
+
+// fetchRange requests the inclusive byte range of the resource and
+// writes it to the file. The number of bytes written is returned.
+func (d *Downloader) fetchRange(
+	ctx context.Context,
+	client *retryablehttp.Client,
+	base *retryablehttp.Request,
+	validator string,
+	f *os.File,
+	start, end int64,
+	prog io.Writer,
+) (int64, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, base.URL.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header = base.Header.Clone()
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		if err = d.checkStatus(GET, resp); err != nil {
+			return 0, err
+		}
+		// The resource changed or the range was ignored
+		return 0, errRangeMismatch
+	}
+	first, last, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, err
+	}
+	if first != start || last != end {
+		return 0, errRangeMismatch
+	}
+
+	length := end - start + 1
+	w := io.NewOffsetWriter(f, start)
+	n, err := io.Copy(io.MultiWriter(w, prog), io.LimitReader(resp.Body, length))
+	if err == nil && n != length {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,70 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadSegmented(t *testing.T) {
+	s := newTestServer(t)
+	sum := sha256.Sum256(testContent)
+	d := &Downloader{config: DownloaderConfig{
+		Src:          s.URL,
+		Dest:         filepath.Join(t.TempDir(), "box"),
+		Segments:     ptr(4),
+		Checksum:     hex.EncodeToString(sum[:]),
+		ChecksumType: "sha256",
+	}}
+
+	if _, err := d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if ranges := s.rangeRequests(); len(ranges) != 4 {
+		t.Fatalf("expected a range request for each segment, got %q", ranges)
+	}
+}
+
+func TestDownloadSegmentedUnsupported(t *testing.T) {
+	s := newTestServer(t)
+	s.ranges = false
+	d := &Downloader{config: DownloaderConfig{
+		Src:      s.URL,
+		Dest:     filepath.Join(t.TempDir(), "box"),
+		Segments: ptr(4),
+	}}
+
+	if _, err := d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+	if ranges := s.rangeRequests(); len(ranges) != 0 {
+		t.Fatalf("expected a single stream, got ranges %q", ranges)
+	}
+}
+
+func TestDownloadSegmentedDigest(t *testing.T) {
+	s := newTestServer(t)
+	d := &Downloader{config: DownloaderConfig{
+		Src:      s.URL,
+		Dest:     filepath.Join(t.TempDir(), "box"),
+		Segments: ptr(4),
+	}}
+
+	result, err := d.Download(DownloadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranges := s.rangeRequests(); len(ranges) != 4 {
+		t.Fatalf("expected a segmented download, got ranges %q", ranges)
+	}
+	sum := sha256.Sum256(testContent)
+	if result.ChecksumType != "sha256" || result.Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected the sha256 digest, got %s %q", result.ChecksumType, result.Checksum)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// transport returns the HTTP transport configured with the
+// TLS and proxy settings of the downloader
+func (d *Downloader) transport() (*http.Transport, error) {
+	t := cleanhttp.DefaultPooledTransport()
+
+	tlsConfig, err := d.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+	t.ResponseHeaderTimeout = valueOf(d.config.AttemptTimeout)
+
+	if d.config.HTTPProxy != "" || d.config.HTTPSProxy != "" || d.config.NoProxy != "" {
+		t.Proxy = d.proxyFunc()
+	}
+
+	return t, nil
+}
+
+// ErrStalled is returned when no data of a response body is
+// received within the attempt timeout
+var ErrStalled = errors.New("no data received within the attempt timeout")
+
+// idleTimeoutTransport cancels a request when reading its response
+// body receives no data within the timeout, so a stalled attempt
+// fails instead of waiting on the connection forever
+type idleTimeoutTransport struct {
+	http.RoundTripper
+
+	timeout time.Duration
+}
+
+// idleTimeout wraps the transport with the configured attempt
+// timeout. If no attempt timeout is configured the transport is
+// returned unchanged.
+func (d *Downloader) idleTimeout(t http.RoundTripper) http.RoundTripper {
+	timeout := valueOf(d.config.AttemptTimeout)
+	if timeout <= 0 {
+		return t
+	}
+	return &idleTimeoutTransport{
+		RoundTripper: t,
+		timeout:      timeout,
+	}
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+
+	b := &idleTimeoutBody{
+		ReadCloser: resp.Body,
+		timeout:    t.timeout,
+		cancel:     cancel,
+	}
+	// The timer only runs while a read is waiting for data
+	b.timer = time.AfterFunc(t.timeout, func() {
+		b.stalled.Store(true)
+		cancel()
+	})
+	b.timer.Stop()
+	resp.Body = b
+	return resp, nil
+}
+
+// idleTimeoutBody is a response body which cancels the request
+// when a read receives no data within the timeout
+type idleTimeoutBody struct {
+	io.ReadCloser
+
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	stalled atomic.Bool
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && b.stalled.Load() {
+		err = ErrStalled
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// tlsConfig builds the TLS configuration. When a CA certificate or
+// path is provided only those certificates are trusted, matching the
+// behavior of the box_download_ca_cert and box_download_ca_path
+// options.
+func (d *Downloader) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: valueOf(d.config.Insecure),
+	}
+
+	if d.config.CACert != "" || d.config.CAPath != "" {
+		pool := x509.NewCertPool()
+		if d.config.CACert != "" {
+			if err := appendCertFile(pool, d.config.CACert); err != nil {
+				return nil, err
+			}
+		}
+		if d.config.CAPath != "" {
+			entries, err := os.ReadDir(d.config.CAPath)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				// Links are followed since certificate directories
+				// like /etc/ssl/certs usually contain links to the
+				// certificates installed elsewhere
+				p := filepath.Join(d.config.CAPath, e.Name())
+				if info, err := os.Stat(p); err != nil || info.IsDir() {
+					continue
+				}
+				// Files which do not contain certificates are ignored.
+				// Certificates linked more than once, like by the hash
+				// links created by c_rehash, are only added once.
+				appendCertFile(pool, p)
+			}
+		}
+		c.RootCAs = pool
+	}
+
+	if d.config.ClientCert != "" {
+		// The key may be included in the certificate file
+		key := d.config.ClientKey
+		if key == "" {
+			key = d.config.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(d.config.ClientCert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// appendCertFile adds the PEM encoded certificates in
+// the file to the pool
+func appendCertFile(pool *x509.CertPool, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in %s", path)
+	}
+	return nil
+}
+
+// proxyFunc returns the proxy function for the transport. Proxy
+// settings which are not configured are read from the environment.
+func (d *Downloader) proxyFunc() func(*http.Request) (*url.URL, error) {
+	c := httpproxy.FromEnvironment()
+	if d.config.HTTPProxy != "" {
+		c.HTTPProxy = d.config.HTTPProxy
+	}
+	if d.config.HTTPSProxy != "" {
+		c.HTTPSProxy = d.config.HTTPSProxy
+	}
+	if d.config.NoProxy != "" {
+		c.NoProxy = d.config.NoProxy
+	}
+
+	fn := c.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}
+}
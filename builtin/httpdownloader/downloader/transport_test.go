@@ -0,0 +1,156 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadCAPathLinks(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testContent)
+	}))
+	t.Cleanup(s.Close)
+
+	// The certificate is installed elsewhere and linked into
+	// the directory, along with a file which is not a certificate
+	dir := t.TempDir()
+	cert := filepath.Join(dir, "server.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	if err := os.WriteFile(cert, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	certs := filepath.Join(dir, "certs")
+	if err := os.Mkdir(certs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(cert, filepath.Join(certs, "server.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(certs, "README"), []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{config: DownloaderConfig{
+		Src:    s.URL,
+		Dest:   filepath.Join(dir, "box"),
+		CAPath: certs,
+	}}
+	if _, err := d.Download(DownloadInput{}); err != nil {
+		t.Fatal(err)
+	}
+	checkContent(t, d.config.Dest)
+}
+
+// stallingServer serves the content, stalling after half of the
+// body of the first response until the request is cancelled
+func stallingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) > 1 {
+			http.ServeContent(w, r, "box", time.Unix(1500000000, 0), bytes.NewReader(testContent))
+			return
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", fmt.Sprint(len(testContent)))
+		w.Write(testContent[:len(testContent)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+func TestDownloadStalled(t *testing.T) {
+	for _, resume := range []bool{true, false} {
+		t.Run(fmt.Sprintf("resume %t", resume), func(t *testing.T) {
+			s, requests := stallingServer(t)
+			d := &Downloader{config: DownloaderConfig{
+				Src:            s.URL,
+				Dest:           filepath.Join(t.TempDir(), "box"),
+				AttemptTimeout: ptr(100 * time.Millisecond),
+				RetryCount:     ptr(1),
+				Resume:         ptr(resume),
+			}}
+
+			result, err := d.Download(DownloadInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkContent(t, d.config.Dest)
+			if n := requests.Load(); n != 2 {
+				t.Fatalf("expected 2 requests, got %d", n)
+			}
+			want := http.StatusOK
+			if resume {
+				want = http.StatusPartialContent
+			}
+			if result.StatusCode != want {
+				t.Fatalf("expected status %d, got %d", want, result.StatusCode)
+			}
+			if result.Attempt != 2 {
+				t.Fatalf("expected the second attempt to succeed, got attempt %d", result.Attempt)
+			}
+		})
+	}
+}
+
+func TestDownloadStalledSharesRetries(t *testing.T) {
+	// The response stalls and every later request fails
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(testContent)))
+		w.Write(testContent[:len(testContent)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(s.Close)
+
+	d := &Downloader{config: DownloaderConfig{
+		Src:            s.URL,
+		Dest:           filepath.Join(t.TempDir(), "box"),
+		AttemptTimeout: ptr(100 * time.Millisecond),
+		RetryCount:     ptr(2),
+		RetryWaitMin:   ptr(time.Millisecond),
+	}}
+
+	_, err := d.Download(DownloadInput{})
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("expected 3 requests, got %d", n)
+	}
+}
+
+func TestDownloadStalledRetriesExhausted(t *testing.T) {
+	s, _ := stallingServer(t)
+	d := &Downloader{config: DownloaderConfig{
+		Src:            s.URL,
+		Dest:           filepath.Join(t.TempDir(), "box"),
+		AttemptTimeout: ptr(100 * time.Millisecond),
+		RetryCount:     ptr(0),
+	}}
+
+	_, err := d.Download(DownloadInput{})
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("expected stalled error, got %v", err)
+	}
+}
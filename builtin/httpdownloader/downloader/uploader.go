@@ -0,0 +1,533 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-argmapper"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/hashicorp/vagrant-plugin-sdk/component"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+)
+
+// contentDigestHeader is the header used to send the checksum
+// of an upload when no checksum header is configured
+const contentDigestHeader = "Content-Digest"
+
+// contentDigestAlgorithms are the names of the checksum types
+// used in the Content-Digest header (RFC 9530)
+var contentDigestAlgorithms = map[string]string{
+	"md5":    "md5",
+	"sha1":   "sha",
+	"sha256": "sha-256",
+	"sha512": "sha-512",
+}
+
+// Uploader sends a local file to an HTTP server. It is registered
+// as a config component for the "uploader" namespace only, since the
+// plugin SDK has no uploader component type. Uploads are available
+// to Go code through an Uploader created with NewUploader.
+type Uploader struct {
+	config UploaderConfig
+}
+
+// NewUploader returns an Uploader for the configuration with
+// default values applied. The configuration is validated when
+// uploading.
+func NewUploader(conf *UploaderConfig) (*Uploader, error) {
+	u := &Uploader{}
+	conf, err := u.Finalize(nil, conf)
+	if err != nil {
+		return nil, err
+	}
+	u.config = *conf
+	return u, nil
+}
+
+// UploadResult describes a completed upload
+type UploadResult struct {
+	// URL is the location the upload was sent to after
+	// following any redirects
+	URL string
+	// StatusCode is the status code of the final response
+	StatusCode int
+	// Header is the header of the final response
+	Header http.Header
+	// Size is the number of bytes of Src which were uploaded
+	Size int64
+	// Checksum is the hex encoded digest sent with the upload
+	// and ChecksumType is the type of the digest. They are empty
+	// when no checksum type is configured.
+	Checksum     string
+	ChecksumType string
+	// Elapsed is the time taken by the upload including any retries
+	Elapsed time.Duration
+	// Attempt is the attempt of the request which succeeded,
+	// starting from 1
+	Attempt int
+}
+
+type UploaderConfig struct {
+	// AttemptTimeout limits how long each attempt waits for the
+	// response headers once the request has been sent, and for
+	// each read of the response body
+	AttemptTimeout *time.Duration
+	// BearerToken is sent in the Authorization header
+	BearerToken string
+	// CACert is a file of PEM encoded CA certificates used to
+	// verify the server instead of the system certificates
+	CACert string
+	// CAPath is a directory of PEM encoded CA certificates used
+	// to verify the server instead of the system certificates
+	CAPath string
+	// ChecksumHeader is the name of the header used to send the
+	// checksum of Src. The hex encoded digest is sent in the header.
+	// When not set the digest is sent in the Content-Digest header,
+	// which does not support sha384.
+	ChecksumHeader string
+	// ChecksumType is the type of checksum of Src sent with the
+	// upload: md5, sha1, sha256, sha384 or sha512. No checksum is
+	// sent when empty.
+	ChecksumType string
+	// ClientCert is a PEM encoded certificate file used for
+	// client certificate authentication
+	ClientCert string
+	// ClientKey is the PEM encoded private key file of ClientCert.
+	// When not set the key is read from ClientCert.
+	ClientKey string
+	// Dest is the http or https URL the file is uploaded to
+	Dest string
+	// FormField is the name of the multipart form field used for
+	// the file. When set the file is sent as multipart/form-data
+	// instead of as the request body.
+	FormField string
+	// FormValues are additional fields of the multipart form
+	FormValues map[string]string
+	Headers    http.Header
+	// HTTPProxy is the proxy used for http requests. Defaults to
+	// the HTTP_PROXY environment variable.
+	HTTPProxy string
+	// HTTPSProxy is the proxy used for https requests. Defaults
+	// to the HTTPS_PROXY environment variable.
+	HTTPSProxy string
+	// Insecure disables verification of the server certificate
+	Insecure *bool
+	// MaxRedirects is the maximum number of redirects which are
+	// followed. Defaults to 10, zero or a negative value disables
+	// redirects.
+	MaxRedirects *int
+	// Method is the request method, either PUT, POST or PATCH.
+	// Defaults to PUT.
+	Method *HTTPMethod
+	// NetrcPath is the path of the netrc file. Defaults to the NETRC
+	// environment variable or the .netrc file in the home directory.
+	NetrcPath string
+	// NoProxy is a comma separated list of hosts which are not
+	// proxied. Defaults to the NO_PROXY environment variable.
+	NoProxy string
+	// Password is used with Username for basic authentication
+	Password string
+	// RetryCount is the number of times a failed request is retried.
+	// It defaults to 3 when the configuration is finalized, zero or
+	// a negative value disables retries.
+	RetryCount *int
+	// RetryJitter adds a random amount of time to each retry wait
+	RetryJitter *bool
+	// RetryStatusCodes are the response status codes which are
+	// retried. When empty, 429 and 5xx responses other than 501
+	// are retried.
+	RetryStatusCodes []int
+	// RetryWaitMin is the minimum time to wait between retries
+	RetryWaitMin *time.Duration
+	// RetryWaitMax is the maximum time to wait between retries
+	RetryWaitMax *time.Duration
+	// Src is the path of the local file to upload
+	Src string
+	// Timeout is the deadline for the complete upload,
+	// including all retries
+	Timeout        *time.Duration
+	UrlQueryParams map[string]string
+	// UseNetrc enables looking up credentials for the destination
+	// host in the netrc file
+	UseNetrc *bool
+	// Username is used with Password for basic authentication
+	Username string
+}
+
+func (u *Uploader) InitFunc() any {
+	return u.Init
+}
+
+func (u *Uploader) Init(in *component.ConfigData) (*component.ConfigData, error) {
+	return in, nil
+}
+
+func (u *Uploader) StructFunc() any {
+	return u.Struct
+}
+
+func (u *Uploader) MergeFunc() any {
+	return u.Merge
+}
+
+func (u *Uploader) FinalizeFunc() any {
+	return u.Finalize
+}
+
+func (u *Uploader) Struct() *UploaderConfig {
+	return &UploaderConfig{}
+}
+
+func (u *Uploader) Merge(
+	input struct {
+		argmapper.Struct
+		Base    *UploaderConfig
+		Overlay *UploaderConfig
+		Log     hclog.Logger
+	},
+) (*UploaderConfig, error) {
+	result := mergeConfig(input.Base, input.Overlay)
+	if input.Log != nil {
+		input.Log.Trace("merged config values in uploader namespace",
+			"src", result.Src, "dest", redactRawURL(result.Dest))
+	}
+
+	return result, nil
+}
+
+// Finalize applies default values to the configuration. It is
+// validated when uploading instead since validating checks that
+// the source file can be read.
+func (u *Uploader) Finalize(l hclog.Logger, conf *UploaderConfig) (*UploaderConfig, error) {
+	if conf == nil {
+		conf = &UploaderConfig{}
+	}
+	if conf.RetryCount == nil {
+		n := defaultRetryCount
+		conf.RetryCount = &n
+	}
+	if conf.Method == nil {
+		method := PUT
+		conf.Method = &method
+	}
+	conf.Headers = canonicalHeader(conf.Headers)
+
+	return conf, nil
+}
+
+func (u *Uploader) Register() (*component.ConfigRegistration, error) {
+	return &component.ConfigRegistration{
+		Identifier: "uploader",
+	}, nil
+}
+
+// Validate checks the configuration for invalid values. All
+// problems are reported, each as a *ConfigError.
+func (c *UploaderConfig) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, &ConfigError{
+			Namespace: "uploader",
+			Field:     field,
+			Reason:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	if c.Src == "" {
+		invalid("Src", "must be set")
+	} else if info, err := os.Stat(c.Src); err != nil {
+		invalid("Src", "cannot be read: %s", err)
+	} else if !info.Mode().IsRegular() {
+		invalid("Src", "is not a regular file")
+	}
+	if c.Dest == "" {
+		invalid("Dest", "must be set")
+	} else if u, err := parseSource(c.Dest); err != nil || !isHTTP(u) || u.Host == "" {
+		invalid("Dest", "is not a valid http or https URL")
+	}
+	if c.Method != nil {
+		switch *c.Method {
+		case PUT, POST, PATCH:
+		default:
+			invalid("Method", "must be PUT, POST or PATCH (%s)", *c.Method)
+		}
+	}
+	if c.ChecksumType != "" {
+		kind := strings.ToLower(c.ChecksumType)
+		if _, ok := checksumTypes[kind]; !ok {
+			invalid("ChecksumType", "is invalid: %s", &ChecksumTypeError{Type: c.ChecksumType})
+		} else if c.ChecksumHeader == "" {
+			// The Content-Digest header describes the request content,
+			// which for a multipart form is not the file itself
+			if c.FormField != "" {
+				invalid("ChecksumHeader", "must be set to send a checksum with a multipart upload")
+			} else if _, ok := contentDigestAlgorithms[kind]; !ok {
+				invalid("ChecksumType", "%s cannot be sent in the %s header, ChecksumHeader must be set",
+					kind, contentDigestHeader)
+			}
+		}
+	}
+	if len(c.FormValues) > 0 && c.FormField == "" {
+		invalid("FormValues", "requires FormField to be set")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (u *Uploader) UploadFunc() interface{} {
+	return u.Upload
+}
+
+// UploadInput is the input of Upload. The progress of the
+// upload is only reported when a UI is provided.
+type UploadInput struct {
+	argmapper.Struct
+	Context context.Context `argmapper:",typeOnly,optional"`
+	UI      terminal.UI     `argmapper:",typeOnly,optional"`
+	Logger  hclog.Logger    `argmapper:",typeOnly,optional"`
+}
+
+// Upload streams the source file to the destination URL. If a UI
+// is provided the progress of the upload is reported to it. The file
+// is read again for each attempt so it is never loaded into memory.
+func (u *Uploader) Upload(input UploadInput) (result *UploadResult, err error) {
+	ctx, ui, logger := input.Context, input.UI, input.Logger
+	start := time.Now()
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if valueOf(u.config.Timeout) > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, valueOf(u.config.Timeout))
+		defer cancel()
+	}
+	if err = u.config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The connection settings are shared with the downloader
+	d := u.downloader()
+	transport, err := d.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	client := retryablehttp.NewClient()
+	client.HTTPClient.Transport = d.idleTimeout(transport)
+	d.configureRetry(client)
+	client.Logger = nil
+	client.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		logger.Debug("sending request", "method", req.Method,
+			"url", redactURL(req.URL), "attempt", attempt+1)
+		recordAttempt(req, attempt+1)
+	}
+	client.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	client.HTTPClient.CheckRedirect = d.checkRedirect
+
+	info, err := os.Stat(u.config.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum must be sent in the headers so the file
+	// is read once to compute it before it is uploaded
+	var sum *checksum
+	if u.config.ChecksumType != "" {
+		kind := strings.ToLower(u.config.ChecksumType)
+		sum = &checksum{Hash: checksumTypes[kind](), Type: kind}
+		if err = sum.ReadFile(u.config.Src); err != nil {
+			return nil, err
+		}
+	}
+
+	name := filepath.Base(u.config.Src)
+	var prefix, suffix []byte
+	contentType := "application/octet-stream"
+	if u.config.FormField != "" {
+		if prefix, suffix, contentType, err = u.multipartEnvelope(name); err != nil {
+			return nil, err
+		}
+	}
+	size := int64(len(prefix)) + info.Size() + int64(len(suffix))
+
+	var prog *progress
+	if ui != nil {
+		prog = newUploadProgress(ui, name, size)
+		defer func() { prog.Finish(err) }()
+	}
+
+	// The body is opened for every attempt so a failed
+	// attempt can be retried from the beginning
+	body := retryablehttp.ReaderFunc(func() (io.Reader, error) {
+		f, err := os.Open(u.config.Src)
+		if err != nil {
+			return nil, err
+		}
+		var r io.Reader = io.MultiReader(bytes.NewReader(prefix), f, bytes.NewReader(suffix))
+		if prog != nil {
+			prog.Restart()
+			r = io.TeeReader(r, prog)
+		}
+		return &readCloser{Reader: r, Closer: f}, nil
+	})
+
+	method := PUT
+	if u.config.Method != nil {
+		method = *u.config.Method
+	}
+	attempts, ctx := trackAttempts(ctx)
+	req, err := retryablehttp.NewRequestWithContext(ctx, method.String(), u.config.Dest, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+
+	if u.config.UrlQueryParams != nil {
+		q := req.URL.Query()
+		for k, v := range u.config.UrlQueryParams {
+			q.Add(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	if u.config.Headers != nil {
+		req.Header = u.config.Headers.Clone()
+	}
+	if req.Header.Get("Content-Type") == "" || u.config.FormField != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if sum != nil {
+		u.setChecksumHeader(req.Header, sum)
+	}
+	d.authenticate(req.Request)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = d.checkStatus(method, resp); err != nil {
+		return nil, err
+	}
+	// Drain the response so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+
+	result = &UploadResult{
+		URL:        redactURL(resp.Request.URL),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Size:       info.Size(),
+		Elapsed:    time.Since(start),
+		Attempt:    max(int(attempts.Load()), 1),
+	}
+	if sum != nil {
+		result.Checksum = sum.Actual()
+		result.ChecksumType = sum.Type
+	}
+
+	return result, nil
+}
+
+// downloader returns a downloader with the connection, authentication
+// and retry settings of the uploader so its client configuration can
+// be reused
+func (u *Uploader) downloader() *Downloader {
+	return &Downloader{
+		config: DownloaderConfig{
+			AttemptTimeout:   u.config.AttemptTimeout,
+			BearerToken:      u.config.BearerToken,
+			CACert:           u.config.CACert,
+			CAPath:           u.config.CAPath,
+			ClientCert:       u.config.ClientCert,
+			ClientKey:        u.config.ClientKey,
+			HTTPProxy:        u.config.HTTPProxy,
+			HTTPSProxy:       u.config.HTTPSProxy,
+			Insecure:         u.config.Insecure,
+			MaxRedirects:     u.config.MaxRedirects,
+			NetrcPath:        u.config.NetrcPath,
+			NoProxy:          u.config.NoProxy,
+			Password:         u.config.Password,
+			RetryCount:       u.config.RetryCount,
+			RetryJitter:      u.config.RetryJitter,
+			RetryStatusCodes: u.config.RetryStatusCodes,
+			RetryWaitMin:     u.config.RetryWaitMin,
+			RetryWaitMax:     u.config.RetryWaitMax,
+			UseNetrc:         u.config.UseNetrc,
+			Username:         u.config.Username,
+		},
+	}
+}
+
+// multipartEnvelope returns the parts of the multipart form which
+// come before and after the content of the file along with the
+// content type of the form. The file content is streamed between
+// them so the form is never assembled in memory.
+func (u *Uploader) multipartEnvelope(name string) (prefix, suffix []byte, contentType string, err error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	keys := make([]string, 0, len(u.config.FormValues))
+	for k := range u.config.FormValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err = mw.WriteField(k, u.config.FormValues[k]); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	if _, err = mw.CreateFormFile(u.config.FormField, name); err != nil {
+		return nil, nil, "", err
+	}
+	prefix = bytes.Clone(buf.Bytes())
+
+	buf.Reset()
+	if err = mw.Close(); err != nil {
+		return nil, nil, "", err
+	}
+	suffix = bytes.Clone(buf.Bytes())
+
+	return prefix, suffix, mw.FormDataContentType(), nil
+}
+
+// setChecksumHeader adds the checksum of the upload to the header
+func (u *Uploader) setChecksumHeader(h http.Header, sum *checksum) {
+	if u.config.ChecksumHeader != "" {
+		h.Set(u.config.ChecksumHeader, sum.Actual())
+		return
+	}
+
+	digest, _ := hex.DecodeString(sum.Actual())
+	h.Set(contentDigestHeader, fmt.Sprintf("%s=:%s:",
+		contentDigestAlgorithms[sum.Type], base64.StdEncoding.EncodeToString(digest)))
+}
+
+// readCloser is a reader which closes the
+// underlying file once the body is closed
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var _ component.Config = (*Uploader)(nil)
@@ -0,0 +1,341 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package downloader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// uploadServer records the uploads it receives
+type uploadServer struct {
+	*httptest.Server
+
+	m        sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newUploadServer(t *testing.T, status ...int) *uploadServer {
+	s := &uploadServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		s.m.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		s.m.Unlock()
+
+		if n < len(status) {
+			w.WriteHeader(status[n])
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// uploads returns the requests and bodies received
+func (s *uploadServer) uploads() ([]*http.Request, [][]byte) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]*http.Request(nil), s.requests...), append([][]byte(nil), s.bodies...)
+}
+
+// testUploadFile writes the file to upload
+func testUploadFile(t *testing.T) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "box")
+	if err := os.WriteFile(p, testContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestUpload(t *testing.T) {
+	s := newUploadServer(t)
+	u, err := NewUploader(&UploaderConfig{
+		Src:  testUploadFile(t),
+		Dest: s.URL + "/box",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := u.Upload(UploadInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != http.StatusCreated || result.Size != int64(len(testContent)) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	requests, bodies := s.uploads()
+	if len(requests) != 1 || requests[0].Method != http.MethodPut {
+		t.Fatalf("expected a single PUT request, got %d", len(requests))
+	}
+	if string(bodies[0]) != string(testContent) {
+		t.Fatalf("expected %d bytes to be uploaded, got %d", len(testContent), len(bodies[0]))
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	s := newUploadServer(t)
+	u, err := NewUploader(&UploaderConfig{
+		Src:  testUploadFile(t),
+		Dest: s.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ui := &testUI{}
+	if _, err = u.Upload(UploadInput{UI: ui}); err != nil {
+		t.Fatal(err)
+	}
+	lines := ui.output()
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "Uploaded box (4.0 MiB in ") {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestUploadMultipart(t *testing.T) {
+	s := newUploadServer(t)
+	method := POST
+	u, err := NewUploader(&UploaderConfig{
+		Src:        testUploadFile(t),
+		Dest:       s.URL,
+		Method:     &method,
+		FormField:  "file",
+		FormValues: map[string]string{"version": "1.0.0", "provider": "virtualbox"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = u.Upload(UploadInput{}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, bodies := s.uploads()
+	if requests[0].Method != http.MethodPost || requests[0].ContentLength != int64(len(bodies[0])) {
+		t.Fatalf("unexpected request %s with length %d", requests[0].Method, requests[0].ContentLength)
+	}
+	mt, params, err := mime.ParseMediaType(requests[0].Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/form-data" {
+		t.Fatalf("expected a multipart form, got %q", requests[0].Header.Get("Content-Type"))
+	}
+	form, err := multipart.NewReader(bytes.NewReader(bodies[0]), params["boundary"]).ReadForm(int64(len(bodies[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	if v := form.Value["version"]; len(v) != 1 || v[0] != "1.0.0" {
+		t.Fatalf("unexpected version field %q", v)
+	}
+	if v := form.Value["provider"]; len(v) != 1 || v[0] != "virtualbox" {
+		t.Fatalf("unexpected provider field %q", v)
+	}
+	files := form.File["file"]
+	if len(files) != 1 || files[0].Filename != "box" {
+		t.Fatalf("expected the file to be sent as box, got %v", files)
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, testContent) {
+		t.Fatalf("expected %d bytes in the form file, got %d", len(testContent), len(data))
+	}
+}
+
+func TestUploadChecksum(t *testing.T) {
+	sha := sha256.Sum256(testContent)
+	sha384 := sha512.Sum384(testContent)
+
+	for _, tc := range []struct {
+		name   string
+		kind   string
+		header string
+		want   string
+	}{
+		{
+			name:   "content digest",
+			kind:   "SHA256",
+			header: "Content-Digest",
+			want:   "sha-256=:" + base64.StdEncoding.EncodeToString(sha[:]) + ":",
+		},
+		{
+			name:   "custom header",
+			kind:   "sha384",
+			header: "X-Checksum-Sha384",
+			want:   hex.EncodeToString(sha384[:]),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newUploadServer(t)
+			conf := &UploaderConfig{
+				Src:          testUploadFile(t),
+				Dest:         s.URL,
+				ChecksumType: tc.kind,
+			}
+			if tc.header != contentDigestHeader {
+				conf.ChecksumHeader = tc.header
+			}
+			u, err := NewUploader(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := u.Upload(UploadInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			requests, _ := s.uploads()
+			if v := requests[0].Header.Get(tc.header); v != tc.want {
+				t.Fatalf("expected %s %q, got %q", tc.header, tc.want, v)
+			}
+			if result.ChecksumType != strings.ToLower(tc.kind) || result.Checksum == "" {
+				t.Fatalf("unexpected result checksum %s:%s", result.ChecksumType, result.Checksum)
+			}
+		})
+	}
+}
+
+func TestUploadRetry(t *testing.T) {
+	s := newUploadServer(t, http.StatusServiceUnavailable)
+	u, err := NewUploader(&UploaderConfig{
+		Src:          testUploadFile(t),
+		Dest:         s.URL,
+		RetryWaitMin: ptr(time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ui := &testUI{}
+	result, err := u.Upload(UploadInput{UI: ui})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Attempt != 2 {
+		t.Fatalf("expected the second attempt to succeed, got attempt %d", result.Attempt)
+	}
+	// The file is sent in full with every attempt
+	_, bodies := s.uploads()
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(bodies))
+	}
+	for i, body := range bodies {
+		if !bytes.Equal(body, testContent) {
+			t.Fatalf("expected attempt %d to send %d bytes, got %d", i+1, len(testContent), len(body))
+		}
+	}
+	if lines := ui.output(); len(lines) != 1 || !strings.HasPrefix(lines[0], "Uploaded box (4.0 MiB in ") {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestUploadStatusError(t *testing.T) {
+	s := newUploadServer(t, http.StatusForbidden)
+	u, err := NewUploader(&UploaderConfig{
+		Src:  testUploadFile(t),
+		Dest: s.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = u.Upload(UploadInput{})
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestUploaderConfigValidate(t *testing.T) {
+	src := testUploadFile(t)
+	get := GET
+
+	for _, tc := range []struct {
+		name   string
+		config UploaderConfig
+		fields []string
+	}{
+		{
+			name:   "valid",
+			config: UploaderConfig{Src: src, Dest: "https://example.com/box", ChecksumType: "sha512"},
+		},
+		{
+			name:   "missing",
+			fields: []string{"Src", "Dest"},
+		},
+		{
+			name:   "source directory",
+			config: UploaderConfig{Src: filepath.Dir(src), Dest: "ftp://example.com/box"},
+			fields: []string{"Src", "Dest"},
+		},
+		{
+			name:   "method",
+			config: UploaderConfig{Src: src, Dest: "https://example.com/box", Method: &get},
+			fields: []string{"Method"},
+		},
+		{
+			name:   "content digest type",
+			config: UploaderConfig{Src: src, Dest: "https://example.com/box", ChecksumType: "sha384"},
+			fields: []string{"ChecksumType"},
+		},
+		{
+			name: "multipart checksum",
+			config: UploaderConfig{Src: src, Dest: "https://example.com/box",
+				ChecksumType: "sha256", FormField: "file"},
+			fields: []string{"ChecksumHeader"},
+		},
+		{
+			name:   "form values",
+			config: UploaderConfig{Src: src, Dest: "https://example.com/box", FormValues: map[string]string{"a": "b"}},
+			fields: []string{"FormValues"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			var fields []string
+			if err != nil {
+				for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+					var cerr *ConfigError
+					if !errors.As(e, &cerr) {
+						t.Fatalf("expected config error, got %v", e)
+					}
+					fields = append(fields, cerr.Field)
+				}
+			}
+			if strings.Join(fields, ",") != strings.Join(tc.fields, ",") {
+				t.Fatalf("expected invalid fields %q, got %q", tc.fields, fields)
+			}
+		})
+	}
+}
@@ -13,6 +13,13 @@ import (
 var PluginOptions = []sdk.Option{
 	sdk.WithComponents(
 		&downloader.Downloader{},
+		// Only provides the uploader configuration namespace,
+		// see downloader.NewUploader for uploading from Go
+		&downloader.Uploader{},
+	),
+	sdk.WithMappers(
+		downloader.ConfigDataToDownloaderConfig,
+		downloader.ConfigDataToUploaderConfig,
 	),
 	sdk.WithName("httpdownloader"),
 }
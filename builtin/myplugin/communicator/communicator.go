@@ -4,58 +4,163 @@
 package communicator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/hashicorp/go-argmapper"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vagrant-plugin-sdk/component"
 	plugincore "github.com/hashicorp/vagrant-plugin-sdk/core"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
 	pb "github.com/hashicorp/vagrant/builtin/myplugin/proto"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// defaultSudoCommand is the command used to run privileged
+	// commands. %c is replaced with the quoted command.
+	defaultSudoCommand = "sudo -E -H sh -c %c"
+	// readyMinInterval and readyMaxInterval bound the delay between
+	// attempts to connect while waiting for the machine to be ready.
+	// The delay doubles after each failed attempt.
+	readyMinInterval = 500 * time.Millisecond
+	readyMaxInterval = 5 * time.Second
+
+	// ptyTerm, ptyHeight and ptyWidth describe the terminal
+	// requested for commands which are run with a pty
+	ptyTerm   = "xterm"
+	ptyHeight = 40
+	ptyWidth  = 80
 )
 
-type DummyConfig struct {
+// SSHConfig configures how the SSHCommunicator connects to machines.
+// The host, port, username and private key are provided by the SSH
+// information of the machine, the configuration supplies the settings
+// which the provider does not.
+type SSHConfig struct {
+	// ConnectTimeout limits how long establishing a connection,
+	// including the SSH handshake, may take. Defaults to 15 seconds.
+	ConnectTimeout time.Duration
+	// KeepAlive is the interval at which keepalive requests are sent
+	// on open connections. Defaults to 5 seconds, a negative value
+	// disables keepalive requests.
+	KeepAlive time.Duration
+	// KnownHostsPath is the known_hosts file used to verify the host
+	// key of the machine. Host keys are not verified when not set.
+	KnownHostsPath string
+	// Password is used for password authentication
+	Password string
+	// PrivateKeyPaths are private keys used for authentication in
+	// addition to the private key provided by the machine
+	PrivateKeyPaths []string
+	// Username is the user to connect as when the machine
+	// does not provide one. Defaults to "vagrant".
+	Username string
+}
+
+// SSHCommunicator is a Communicator implementation which
+// connects to the machine using SSH.
+type SSHCommunicator struct {
+	config SSHConfig
+
+	// Dialer opens the network connection to the SSH server. When
+	// not set the connection is made over TCP. It allows connecting
+	// to an in-process server when testing.
+	Dialer Dialer
+
+	m       sync.Mutex
+	clients map[string]*ssh.Client
 }
 
-// DummyCommunicator is a Communicator implementation for myplugin.
-type DummyCommunicator struct {
-	config DummyConfig
+// NewSSHCommunicator returns a communicator which connects to
+// machines with the configuration
+func NewSSHCommunicator(config SSHConfig) *SSHCommunicator {
+	return &SSHCommunicator{config: config}
 }
 
-func (h *DummyCommunicator) MatchFunc() interface{} {
+func (h *SSHCommunicator) MatchFunc() interface{} {
 	return h.Match
 }
 
-func (h *DummyCommunicator) Match(machine plugincore.Machine) (isMatch bool, err error) {
+func (h *SSHCommunicator) Match(machine plugincore.Machine) (isMatch bool, err error) {
 	return true, nil
 }
 
-func (h *DummyCommunicator) InitFunc() interface{} {
+func (h *SSHCommunicator) InitFunc() interface{} {
 	return h.Init
 }
 
-func (h *DummyCommunicator) Init(machine plugincore.Machine) error {
+func (h *SSHCommunicator) Init(machine plugincore.Machine) error {
 	return nil
 }
 
-func (h *DummyCommunicator) ReadyFunc() interface{} {
+func (h *SSHCommunicator) ReadyFunc() interface{} {
 	return h.Ready
 }
 
-func (h *DummyCommunicator) Ready(machine plugincore.Machine) (isReady bool, err error) {
-	return false, nil
+// Ready returns if a connection to the machine can be established
+func (h *SSHCommunicator) Ready(machine plugincore.Machine) (isReady bool, err error) {
+	if _, err = h.connect(context.Background(), machine); err != nil {
+		return false, nil
+	}
+	return true, nil
 }
 
-func (h *DummyCommunicator) WaitForReadyFunc() interface{} {
+func (h *SSHCommunicator) WaitForReadyFunc() interface{} {
 	return h.WaitForReady
 }
 
-func (h *DummyCommunicator) WaitForReady(machine plugincore.Machine, wait int) (isReady bool, err error) {
-	return false, nil
+// WaitForReady waits up to the given number of seconds for the
+// machine to be ready, reporting the progress to the UI of the
+// machine. Waiting stops early when the machine is no longer
+// running or the connection fails with ErrAuthFailed or
+// ErrHostKeyMismatch. When the machine is not ready the error
+// of the last attempt to connect is returned.
+func (h *SSHCommunicator) WaitForReady(machine plugincore.Machine, wait int) (isReady bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wait)*time.Second)
+	defer cancel()
+
+	progress := newReadyProgress(machine)
+	defer func() {
+		progress.Finish(err)
+	}()
+
+	interval := readyMinInterval
+	for {
+		if err = machineRunning(machine); err != nil {
+			return false, err
+		}
+		if _, err = h.connect(ctx, machine); err == nil {
+			return true, nil
+		}
+		if !retryable(err) {
+			return false, err
+		}
+		progress.Retry(err)
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("timed out waiting for the machine to be ready: %w", err)
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, readyMaxInterval)
+	}
 }
 
-func (h *DummyCommunicator) DownloadFunc() interface{} {
+func (h *SSHCommunicator) DownloadFunc() interface{} {
 	return h.Download
 }
 
-func (h *DummyCommunicator) Download(input struct {
+// Download copies the file or directory at the source path on
+// the machine to the destination path on the host using SFTP,
+// or SCP when SFTP is not available. A source containing glob
+// characters downloads all matches into the destination.
+// Modes and modification times are preserved.
+func (h *SSHCommunicator) Download(input struct {
 	argmapper.Struct
 	Machine     plugincore.Machine `argmapper:",typeOnly"`
 	Logger      hclog.Logger       `argmapper:",typeOnly"`
@@ -63,16 +168,31 @@ func (h *DummyCommunicator) Download(input struct {
 	Destination string
 },
 ) error {
-	input.Logger.Debug("got Source ", input.Source)
-	input.Logger.Debug("got Destination ", input.Destination)
-	return nil
+	input.Logger.Debug("downloading", "source", input.Source, "destination", input.Destination)
+
+	client, err := h.connect(context.Background(), input.Machine)
+	if err != nil {
+		return err
+	}
+
+	progress := newTransferProgress(input.Machine, "Download")
+	err = download(client, input.Source, input.Destination, progress)
+	progress.Finish(err)
+	return err
 }
 
-func (h *DummyCommunicator) UploadFunc() interface{} {
+func (h *SSHCommunicator) UploadFunc() interface{} {
 	return h.Upload
 }
 
-func (h *DummyCommunicator) Upload(input struct {
+// Upload copies the file or directory at the source path on the
+// host to the destination path on the machine using SFTP, or SCP
+// when SFTP is not available. Directories are uploaded into the
+// destination unless the source ends with "/.", in which case only
+// the contents of the directory are uploaded. A source containing
+// glob characters uploads all matches into the destination.
+// Modes and modification times are preserved.
+func (h *SSHCommunicator) Upload(input struct {
 	argmapper.Struct
 	Machine     plugincore.Machine `argmapper:",typeOnly"`
 	Logger      hclog.Logger       `argmapper:",typeOnly"`
@@ -80,55 +200,308 @@ func (h *DummyCommunicator) Upload(input struct {
 	Destination string
 },
 ) error {
-	input.Logger.Debug("got Source ", input.Source)
-	input.Logger.Debug("got Destination ", input.Destination)
-	return nil
+	input.Logger.Debug("uploading", "source", input.Source, "destination", input.Destination)
+
+	client, err := h.connect(context.Background(), input.Machine)
+	if err != nil {
+		return err
+	}
+
+	progress := newTransferProgress(input.Machine, "Upload")
+	err = upload(client, input.Source, input.Destination, progress)
+	progress.Finish(err)
+	return err
 }
 
-func (h *DummyCommunicator) ExecuteFunc() interface{} {
+func (h *SSHCommunicator) ExecuteFunc() interface{} {
 	return h.Execute
 }
 
-func (h *DummyCommunicator) Execute(
-	machine plugincore.Machine,
-	command []string,
-	options *pb.CommunicatorOptions,
-) (status int32, err error) {
-	return 0, nil
+// ExecuteInput is the input of Execute and PrivilegedExecute. The
+// output of the command is only streamed when a UI or an output
+// function is provided, and only buffered in the result when
+// Capture is set.
+type ExecuteInput struct {
+	argmapper.Struct
+	Machine plugincore.Machine      `argmapper:",typeOnly"`
+	Command []string                `argmapper:",typeOnly"`
+	Options *pb.CommunicatorOptions `argmapper:",typeOnly,optional"`
+	UI      terminal.UI             `argmapper:",typeOnly,optional"`
+	Output  OutputFunc              `argmapper:",typeOnly,optional"`
+	Capture bool                    `argmapper:",optional"`
 }
 
-func (h *DummyCommunicator) PrivilegedExecuteFunc() interface{} {
+func (in *ExecuteInput) execOptions() *ExecOptions {
+	return &ExecOptions{UI: in.UI, Output: in.Output, Capture: in.Capture}
+}
+
+// Execute runs the command on the machine and returns its exit
+// status along with the output when it is captured. The parts of the command
+// are joined with spaces and run by the login shell of the user.
+// The output is streamed line by line as set in the input.
+func (h *SSHCommunicator) Execute(input ExecuteInput) (status int32, result *ExecResult, err error) {
+	result, err = h.Exec(input.Machine, input.Command, input.Options, input.execOptions())
+	if result == nil {
+		return -1, nil, err
+	}
+	return result.ExitCode, result, err
+}
+
+func (h *SSHCommunicator) PrivilegedExecuteFunc() interface{} {
 	return h.PrivilegedExecute
 }
 
-func (h *DummyCommunicator) PrivilegedExecute(
+// PrivilegedExecute runs the command on the machine using sudo
+// and returns its exit status along with the output when it is
+// captured.
+// The output is streamed line by line as set in the input.
+func (h *SSHCommunicator) PrivilegedExecute(input ExecuteInput) (status int32, result *ExecResult, err error) {
+	result, err = h.PrivilegedExec(input.Machine, input.Command, input.Options, input.execOptions())
+	if result == nil {
+		return -1, nil, err
+	}
+	return result.ExitCode, result, err
+}
+
+// Exec runs the command on the machine like Execute. The output
+// of the command is streamed and captured as set in the exec
+// options, which may be nil to discard it. When error checking
+// is enabled a non-zero exit status returns a *CommandError
+// along with the result.
+func (h *SSHCommunicator) Exec(
+	machine plugincore.Machine,
+	command []string,
+	options *pb.CommunicatorOptions,
+	exec *ExecOptions,
+) (*ExecResult, error) {
+	return h.run(machine, command, options, false, exec)
+}
+
+// PrivilegedExec runs the command on the machine using sudo
+// like PrivilegedExecute. The output of the command is handled
+// as set in the exec options.
+func (h *SSHCommunicator) PrivilegedExec(
 	machine plugincore.Machine,
 	command []string,
 	options *pb.CommunicatorOptions,
-) (status int32, err error) {
-	return 0, nil
+	exec *ExecOptions,
+) (*ExecResult, error) {
+	return h.run(machine, command, options, true, exec)
 }
 
-func (h *DummyCommunicator) TestFunc() interface{} {
+func (h *SSHCommunicator) TestFunc() interface{} {
 	return h.Test
 }
 
-func (h *DummyCommunicator) Test(
+// Test returns if the command exits successfully on the machine
+func (h *SSHCommunicator) Test(
 	machine plugincore.Machine,
 	command []string,
 	options ...pb.CommunicatorOptions,
 ) (valid bool, err error) {
-	return true, nil
+	var opts *pb.CommunicatorOptions
+	if len(options) > 0 {
+		opts = &options[0]
+	}
+
+	result, err := h.run(machine, command, opts, false, nil)
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return result.ExitCode == 0, nil
 }
 
-func (h *DummyCommunicator) ResetFunc() interface{} {
+func (h *SSHCommunicator) ResetFunc() interface{} {
 	return h.Reset
 }
 
-func (h *DummyCommunicator) Reset(machine plugincore.Machine) (err error) {
+// Reset closes the connection to the machine so the
+// next request establishes a new connection
+func (h *SSHCommunicator) Reset(machine plugincore.Machine) (err error) {
+	id, err := machine.ID()
+	if err != nil {
+		return err
+	}
+
+	h.m.Lock()
+	client, ok := h.clients[id]
+	delete(h.clients, id)
+	h.m.Unlock()
+
+	if ok {
+		client.Close()
+	}
 	return nil
 }
 
+// run executes the command in a new session with the options
+// applied and returns its exit status along with any captured
+// output. Privileged commands are run using the sudo command.
+func (h *SSHCommunicator) run(
+	machine plugincore.Machine,
+	command []string,
+	options *pb.CommunicatorOptions,
+	privileged bool,
+	exec *ExecOptions,
+) (*ExecResult, error) {
+	cmd, err := buildCommand(command, options, privileged)
+	if err != nil {
+		return nil, err
+	}
+	keepAlive, err := parseKeepAlive(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.GetTimeout() < 0 {
+		return nil, fmt.Errorf("invalid command timeout %d", options.GetTimeout())
+	}
+
+	client, err := h.connect(context.Background(), machine)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	if options.GetPty() {
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          0,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err = session.RequestPty(ptyTerm, ptyHeight, ptyWidth, modes); err != nil {
+			return nil, fmt.Errorf("failed to allocate pty: %w", err)
+		}
+	}
+	if stdin := options.GetStdin(); stdin != "" {
+		session.Stdin = strings.NewReader(stdin)
+	}
+
+	// The error output is needed for the error when checking
+	// the exit status, even if it is not otherwise captured
+	capture := exec != nil && exec.Capture
+	if options.GetErrorCheck() && !capture {
+		var opts ExecOptions
+		if exec != nil {
+			opts = *exec
+		}
+		opts.Capture = true
+		exec = &opts
+	}
+
+	out := newOutput(exec)
+	session.Stdout, session.Stderr = out.Writers()
+
+	if err = session.Start(cmd); err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(options.GetTimeout()) * time.Second
+	status, err := exitStatus(wait(client, session, timeout, keepAlive))
+	if err != nil {
+		return nil, err
+	}
+
+	result := out.Finish(status)
+	stderr := result.Stderr
+	if !capture {
+		result.Stdout, result.Stderr = "", ""
+	}
+	if options.GetErrorCheck() && status != 0 {
+		return result, &CommandError{
+			Command:  strings.Join(command, " "),
+			ExitCode: status,
+			Stderr:   stderr,
+		}
+	}
+	return result, nil
+}
+
+// wait waits for the command to exit. The command is killed if it
+// runs longer than the timeout, and keepalive requests are sent at
+// the given interval while it runs. Zero values disable either.
+func wait(client *ssh.Client, session *ssh.Session, timeout, keepAlive time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var tick <-chan time.Time
+	if keepAlive > 0 {
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-tick:
+			// Requests only fail once the connection is closed,
+			// which also ends the session with an error
+			client.SendRequest("keepalive@openssh.com", true, nil)
+		case <-expired:
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+			return fmt.Errorf("command timed out after %s", timeout)
+		}
+	}
+}
+
+// exitStatus returns the exit status of a command from the
+// error returned when it was run
+func exitStatus(err error) (int32, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return int32(exitErr.ExitStatus()), nil
+	}
+	return -1, err
+}
+
+// shellQuote quotes the value so it is passed to a POSIX
+// shell as a single word
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// shellGlobQuote quotes the value like shellQuote but leaves the
+// glob characters unquoted so they are expanded by the shell
+func shellGlobQuote(v string) string {
+	var b strings.Builder
+	start := 0
+	for i, c := range v {
+		if strings.ContainsRune("*?[]", c) {
+			if i > start {
+				b.WriteString(shellQuote(v[start:i]))
+			}
+			b.WriteRune(c)
+			start = i + 1
+		}
+	}
+	if start < len(v) {
+		b.WriteString(shellQuote(v[start:]))
+	}
+	return b.String()
+}
+
 var (
-	_ component.Communicator = (*DummyCommunicator)(nil)
+	_ component.Communicator = (*SSHCommunicator)(nil)
 )
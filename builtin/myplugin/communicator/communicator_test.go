@@ -0,0 +1,426 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	plugincore "github.com/hashicorp/vagrant-plugin-sdk/core"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+	pb "github.com/hashicorp/vagrant/builtin/myplugin/proto"
+	"golang.org/x/crypto/ssh"
+)
+
+const testPassword = "secret"
+
+// testServer is an in-process SSH server which runs commands
+// locally with sh and serves registered subsystems
+type testServer struct {
+	config     *ssh.ServerConfig
+	signer     ssh.Signer
+	listener   net.Listener
+	dir        string
+	subsystems map[string]func(ssh.Channel)
+
+	m     sync.Mutex
+	cmds  []string
+	users []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		signer:     signer,
+		dir:        t.TempDir(),
+		subsystems: map[string]func(ssh.Channel){},
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			s.m.Lock()
+			s.users = append(s.users, c.User())
+			s.m.Unlock()
+			if string(pass) != testPassword {
+				return nil, errors.New("invalid password")
+			}
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(signer)
+
+	if s.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.listener.Close() })
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// dialer connects to the server regardless of the address
+func (s *testServer) dialer() Dialer {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, s.listener.Addr().String())
+	}
+}
+
+// commands returns the commands which have been run
+func (s *testServer) commands() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string(nil), s.cmds...)
+}
+
+// logins returns the users which have authenticated
+func (s *testServer) logins() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]string(nil), s.users...)
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go s.session(ch, chReqs)
+	}
+}
+
+func (s *testServer) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	pty := false
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			pty = true
+			req.Reply(true, nil)
+		case "env":
+			req.Reply(true, nil)
+		case "subsystem":
+			fn, ok := s.subsystems[string(req.Payload[4:])]
+			req.Reply(ok, nil)
+			if ok {
+				fn(ch)
+				sendExitStatus(ch, 0)
+			}
+			return
+		case "exec":
+			req.Reply(true, nil)
+			sendExitStatus(ch, s.exec(ch, string(req.Payload[4:]), pty))
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// exec runs the command in the directory of the server
+// and returns its exit status
+func (s *testServer) exec(ch ssh.Channel, command string, pty bool) uint32 {
+	s.m.Lock()
+	s.cmds = append(s.cmds, command)
+	s.m.Unlock()
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = s.dir
+	cmd.Env = append(os.Environ(), "HOME="+s.dir)
+	cmd.Stdout = ch
+	cmd.Stderr = ch.Stderr()
+	if pty {
+		cmd.Stderr = ch
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 127
+	}
+	go func() {
+		io.Copy(stdin, ch)
+		stdin.Close()
+	}()
+
+	err = cmd.Run()
+	ch.CloseWrite()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return uint32(exitErr.ExitCode())
+	}
+	return 127
+}
+
+func sendExitStatus(ch ssh.Channel, status uint32) {
+	ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+}
+
+// testUI records the output of the UI
+type testUI struct {
+	terminal.UI
+
+	m     sync.Mutex
+	lines []string
+}
+
+func (u *testUI) Output(msg string, raw ...interface{}) {
+	var args []interface{}
+	for _, v := range raw {
+		if _, ok := v.(terminal.Option); !ok {
+			args = append(args, v)
+		}
+	}
+
+	u.m.Lock()
+	defer u.m.Unlock()
+	u.lines = append(u.lines, fmt.Sprintf(msg, args...))
+}
+
+func (u *testUI) Interactive() bool     { return false }
+func (u *testUI) MachineReadable() bool { return false }
+
+func (u *testUI) output() []string {
+	u.m.Lock()
+	defer u.m.Unlock()
+	return append([]string(nil), u.lines...)
+}
+
+type testProvider struct {
+	plugincore.Provider
+	info  *plugincore.SshInfo
+	state string
+}
+
+func (p *testProvider) SshInfo() (*plugincore.SshInfo, error) {
+	return p.info, nil
+}
+
+func (p *testProvider) State() (*plugincore.MachineState, error) {
+	if p.state == "" {
+		return &plugincore.MachineState{ID: runningState}, nil
+	}
+	return &plugincore.MachineState{ID: p.state}, nil
+}
+
+type testMachine struct {
+	plugincore.Machine
+	ui       *testUI
+	provider *testProvider
+}
+
+func (m *testMachine) ID() (string, error)                    { return "default", nil }
+func (m *testMachine) UI() (terminal.UI, error)               { return m.ui, nil }
+func (m *testMachine) Provider() (plugincore.Provider, error) { return m.provider, nil }
+
+// testCommunicator returns a communicator connected to a new
+// test server and a machine using it
+func testCommunicator(t *testing.T) (*SSHCommunicator, *testMachine, *testServer) {
+	s := newTestServer(t)
+	h := NewSSHCommunicator(SSHConfig{Password: testPassword, KeepAlive: -1})
+	h.Dialer = s.dialer()
+
+	m := &testMachine{
+		ui: &testUI{},
+		provider: &testProvider{
+			info: &plugincore.SshInfo{Host: "127.0.0.1"},
+		},
+	}
+	t.Cleanup(func() { h.Reset(m) })
+	return h, m, s
+}
+
+func TestExecuteStatus(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+
+	status, result, err := h.Execute(ExecuteInput{
+		Machine: m,
+		Command: []string{"echo hello; exit 4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 4 {
+		t.Fatalf("expected status 4, got %d", status)
+	}
+	if result.Stdout != "" {
+		t.Fatalf("expected no output without capture, got %q", result.Stdout)
+	}
+	if lines := m.ui.output(); len(lines) != 0 {
+		t.Fatalf("expected no output without a UI, got %q", lines)
+	}
+
+	_, result, err = h.Execute(ExecuteInput{
+		Machine: m,
+		Command: []string{"echo hello"},
+		Capture: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "hello\n" {
+		t.Fatalf("expected captured output, got %q", result.Stdout)
+	}
+}
+
+func TestExecuteStreamsOutput(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+
+	var mu sync.Mutex
+	var streams []string
+	_, _, err := h.Execute(ExecuteInput{
+		Machine: m,
+		Command: []string{"echo out; echo err >&2; printf partial"},
+		UI:      m.ui,
+		Output: func(stream Stream, line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			streams = append(streams, stream.String()+": "+line)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := m.ui.output()
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines of output, got %q", lines)
+	}
+	for _, want := range []string{"stdout: out", "stderr: err", "stdout: partial"} {
+		found := false
+		for _, line := range streams {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("expected output %q in %q", want, streams)
+		}
+	}
+}
+
+func TestExecCapture(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+
+	result, err := h.Exec(m, []string{"echo hello; echo oops >&2"}, nil, &ExecOptions{Capture: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "hello\n" || result.Stderr != "oops\n" {
+		t.Fatalf("unexpected output %q %q", result.Stdout, result.Stderr)
+	}
+}
+
+func TestExecOptions(t *testing.T) {
+	h, m, s := testCommunicator(t)
+
+	options := &pb.CommunicatorOptions{
+		Env:        map[string]string{"GREETING": "hello world"},
+		WorkingDir: "/",
+	}
+	result, err := h.Exec(m, []string{"echo $GREETING; pwd"}, options, &ExecOptions{Capture: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "hello world\n/\n" {
+		t.Fatalf("unexpected output %q", result.Stdout)
+	}
+
+	options = &pb.CommunicatorOptions{SudoCommand: "env PRIVILEGED=1 sh -c %c"}
+	result, err = h.PrivilegedExec(m, []string{"echo $PRIVILEGED"}, options, &ExecOptions{Capture: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "1\n" {
+		t.Fatalf("unexpected output %q", result.Stdout)
+	}
+	cmds := s.commands()
+	if last := cmds[len(cmds)-1]; !strings.HasPrefix(last, "env PRIVILEGED=1 ") {
+		t.Fatalf("unexpected command %q", last)
+	}
+}
+
+func TestExecWorkingDirHome(t *testing.T) {
+	h, m, s := testCommunicator(t)
+
+	dir := filepath.Join(s.dir, "it's here")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ dir, want string }{
+		{dir: "~", want: s.dir},
+		{dir: "~/it's here", want: dir},
+	} {
+		options := &pb.CommunicatorOptions{WorkingDir: tc.dir}
+		result, err := h.Exec(m, []string{"pwd"}, options, &ExecOptions{Capture: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(result.Stdout); got != tc.want {
+			t.Errorf("expected %q to change to %q, got %q", tc.dir, tc.want, got)
+		}
+	}
+}
+
+func TestExecErrorCheck(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+
+	options := &pb.CommunicatorOptions{ErrorCheck: true}
+	_, err := h.Exec(m, []string{"echo failed >&2; exit 2"}, options, nil)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected command error, got %v", err)
+	}
+	if cmdErr.ExitCode != 2 || cmdErr.Stderr != "failed\n" {
+		t.Fatalf("unexpected command error %#v", cmdErr)
+	}
+}
+
+func TestTest(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+
+	for command, want := range map[string]bool{"true": true, "false": false} {
+		ok, err := h.Test(m, []string{command})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("expected %s to return %t", command, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hashicorp/vagrant/builtin/myplugin/proto"
+)
+
+// envName matches the names of environment variables
+// which can be exported by the shell
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CommandError is returned when error checking is enabled
+// and a command exits with a non-zero status
+type CommandError struct {
+	// Command is the command which was run
+	Command string
+	// ExitCode is the exit status of the command
+	ExitCode int32
+	// Stderr is the error output of the command
+	Stderr string
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("command %q exited with status %d", e.Command, e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// buildCommand returns the command run on the machine with the
+// environment, working directory, shell and sudo command of the
+// options applied
+func buildCommand(command []string, options *pb.CommunicatorOptions, privileged bool) (string, error) {
+	var b strings.Builder
+
+	env := options.GetEnv()
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if !envName.MatchString(name) {
+			return "", fmt.Errorf("invalid environment variable name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s=%s; ", name, shellQuote(env[name]))
+	}
+
+	if dir := options.GetWorkingDir(); dir != "" {
+		fmt.Fprintf(&b, "cd %s && ", shellHomeQuote(dir))
+	}
+	b.WriteString(strings.Join(command, " "))
+
+	cmd := b.String()
+	if shell := options.GetShell(); shell != "" {
+		cmd = shell + " -c " + shellQuote(cmd)
+	}
+	if !privileged {
+		return cmd, nil
+	}
+
+	sudo := options.GetSudoCommand()
+	if sudo == "" {
+		sudo = defaultSudoCommand
+	}
+	if !strings.Contains(sudo, "%c") {
+		sudo += " %c"
+	}
+	return strings.ReplaceAll(sudo, "%c", shellQuote(cmd)), nil
+}
+
+// shellHomeQuote quotes the path like shellQuote, except for a
+// leading ~ which is replaced with the home directory of the user
+// as the shell does not expand it within quotes
+func shellHomeQuote(p string) string {
+	switch {
+	case p == "~":
+		return `"$HOME"`
+	case strings.HasPrefix(p, "~/"):
+		return `"$HOME"/` + shellQuote(p[2:])
+	}
+	return shellQuote(p)
+}
+
+// parseKeepAlive parses the keepalive interval of the options,
+// given either as a duration or a number of seconds
+func parseKeepAlive(options *pb.CommunicatorOptions) (time.Duration, error) {
+	v := options.GetKeepAlive()
+	if v == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid keep alive interval %q", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,144 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+)
+
+// Stream identifies an output stream of a command
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+func (s Stream) String() string {
+	if s == Stderr {
+		return "stderr"
+	}
+	return "stdout"
+}
+
+// OutputFunc is called with each line of output of a command
+type OutputFunc func(stream Stream, line string)
+
+// ExecOptions controls how the output of a command is handled
+type ExecOptions struct {
+	// UI receives the output of the command line by line.
+	// Lines written to stderr use the error style.
+	UI terminal.UI
+	// Output is called with each line of output
+	Output OutputFunc
+	// Capture stores the output of the command in the result
+	Capture bool
+}
+
+// ExecResult is the result of running a command
+type ExecResult struct {
+	// ExitCode is the exit status of the command
+	ExitCode int32
+	// Stdout and Stderr are the output of the command
+	// when it is captured
+	Stdout string
+	Stderr string
+}
+
+// output dispatches the output of a command. Each stream is written
+// from its own goroutine so lines are delivered under a lock.
+type output struct {
+	opts *ExecOptions
+
+	m      sync.Mutex
+	stdout *lineWriter
+	stderr *lineWriter
+
+	stdoutBuf bytes.Buffer
+	stderrBuf bytes.Buffer
+}
+
+func newOutput(opts *ExecOptions) *output {
+	if opts == nil {
+		opts = &ExecOptions{}
+	}
+	o := &output{opts: opts}
+	o.stdout = &lineWriter{fn: func(line string) { o.line(Stdout, line) }}
+	o.stderr = &lineWriter{fn: func(line string) { o.line(Stderr, line) }}
+	return o
+}
+
+// Writers returns the writers for the stdout and stderr of the
+// command, or nil when the output is not used
+func (o *output) Writers() (stdout, stderr io.Writer) {
+	if o.opts.UI == nil && o.opts.Output == nil && !o.opts.Capture {
+		return nil, nil
+	}
+	if !o.opts.Capture {
+		return o.stdout, o.stderr
+	}
+	return io.MultiWriter(o.stdout, &o.stdoutBuf), io.MultiWriter(o.stderr, &o.stderrBuf)
+}
+
+// Finish delivers any incomplete final lines and returns
+// the result of the command
+func (o *output) Finish(exitCode int32) *ExecResult {
+	o.stdout.Flush()
+	o.stderr.Flush()
+
+	result := &ExecResult{ExitCode: exitCode}
+	if o.opts.Capture {
+		result.Stdout = o.stdoutBuf.String()
+		result.Stderr = o.stderrBuf.String()
+	}
+	return result
+}
+
+func (o *output) line(stream Stream, line string) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	if o.opts.UI != nil {
+		if stream == Stderr {
+			o.opts.UI.Output("%s", line, terminal.WithErrorStyle())
+		} else {
+			o.opts.UI.Output("%s", line)
+		}
+	}
+	if o.opts.Output != nil {
+		o.opts.Output(stream, line)
+	}
+}
+
+// lineWriter calls fn with each complete line written to it
+type lineWriter struct {
+	fn  func(string)
+	buf []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush calls fn with any remaining incomplete line
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.fn(strings.TrimSuffix(string(w.buf), "\r"))
+		w.buf = nil
+	}
+}
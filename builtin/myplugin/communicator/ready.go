@@ -0,0 +1,185 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	plugincore "github.com/hashicorp/vagrant-plugin-sdk/core"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// runningState is the state of the machine
+	// in which it can be connected to
+	runningState = "running"
+	// readyWarningInterval is how long a repeated warning is not
+	// shown again while waiting for the machine to be ready
+	readyWarningInterval = 10 * time.Second
+)
+
+var (
+	// ErrConnectionRefused is returned when the machine refuses
+	// the connection, usually because SSH is not running yet
+	ErrConnectionRefused = errors.New("connection refused")
+	// ErrAuthFailed is returned when none of the credentials
+	// are accepted by the machine
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrHostKeyMismatch is returned when the host key of the
+	// machine does not match the known hosts file
+	ErrHostKeyMismatch = errors.New("host key verification failed")
+	// ErrNotRunning is returned when the machine stops running
+	// while waiting for it to be ready
+	ErrNotRunning = errors.New("machine is not running")
+)
+
+// connectError classifies the error from establishing
+// a connection to the machine
+func connectError(err error) error {
+	var keyErr *knownhosts.KeyError
+	switch {
+	case errors.As(err, &keyErr):
+		return fmt.Errorf("%w: %w", ErrHostKeyMismatch, err)
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("%w: %w", ErrConnectionRefused, err)
+	}
+	return err
+}
+
+// retryable returns if waiting may resolve the error
+// from establishing a connection
+func retryable(err error) bool {
+	return !errors.Is(err, ErrAuthFailed) && !errors.Is(err, ErrHostKeyMismatch)
+}
+
+// readyMessage returns the warning shown for the error
+// from establishing a connection, if any
+func readyMessage(err error) string {
+	var netErr interface{ Timeout() bool }
+	switch {
+	case errors.Is(err, errNoSSHInfo):
+		return "SSH information is not available yet."
+	case errors.Is(err, ErrConnectionRefused):
+		return "Connection refused."
+	case errors.Is(err, syscall.ECONNRESET):
+		return "Connection reset."
+	case errors.Is(err, syscall.ECONNABORTED):
+		return "Connection aborted."
+	case errors.Is(err, syscall.EHOSTDOWN):
+		return "Host appears down."
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "Host unreachable."
+	case errors.Is(err, io.EOF):
+		return "Remote connection disconnect."
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return "Connection timeout."
+	}
+	return ""
+}
+
+// machineRunning returns an error if the provider
+// reports the machine is no longer running
+func machineRunning(machine plugincore.Machine) error {
+	provider, err := machine.Provider()
+	if err != nil {
+		return err
+	}
+	state, err := provider.State()
+	if err != nil {
+		return err
+	}
+	if state != nil && state.ID != runningState {
+		return fmt.Errorf("%w: machine state is %q", ErrNotRunning, state.ID)
+	}
+	return nil
+}
+
+// readyProgress reports the progress of waiting for the machine
+// to be ready. A status is updated when the UI is interactive,
+// otherwise warnings are output without repeating them too often.
+type readyProgress struct {
+	ui     terminal.UI
+	status terminal.Status
+	start  time.Time
+	warned map[string]time.Time
+}
+
+func newReadyProgress(machine plugincore.Machine) *readyProgress {
+	p := &readyProgress{
+		start:  time.Now(),
+		warned: map[string]time.Time{},
+	}
+	if ui, err := machine.UI(); err == nil && ui != nil {
+		p.ui = ui
+	}
+	if p.ui == nil {
+		return p
+	}
+
+	msg := "Waiting for machine to be ready..."
+	if p.ui.Interactive() && !p.ui.MachineReadable() {
+		p.status = p.ui.Status()
+		p.status.Update(msg)
+	} else {
+		p.ui.Output(msg, terminal.WithInfoStyle())
+	}
+	return p
+}
+
+// Retry reports the error of a failed attempt to connect
+func (p *readyProgress) Retry(err error) {
+	msg := readyMessage(err)
+	if p.ui == nil || msg == "" {
+		return
+	}
+
+	if p.status != nil {
+		p.status.Update(fmt.Sprintf("Waiting for machine to be ready (%s Retrying, %s elapsed)",
+			msg, time.Since(p.start).Round(time.Second)))
+		return
+	}
+	if last, ok := p.warned[msg]; ok && time.Since(last) < readyWarningInterval {
+		return
+	}
+	p.warned[msg] = time.Now()
+	p.ui.Output("Warning: %s Retrying...", msg, terminal.WithWarningStyle())
+}
+
+// Finish reports the result of waiting for the machine
+func (p *readyProgress) Finish(err error) {
+	if p.ui == nil {
+		return
+	}
+
+	elapsed := time.Since(p.start).Round(time.Second)
+	if err != nil {
+		msg := fmt.Sprintf("Machine was not ready after %s: %s", elapsed, err)
+		if p.status != nil {
+			p.status.Step(terminal.StatusError, msg)
+			p.status.Close()
+		} else {
+			p.ui.Output("%s", msg, terminal.WithErrorStyle())
+		}
+		return
+	}
+
+	msg := fmt.Sprintf("Machine is ready (%s)", elapsed)
+	if p.status != nil {
+		p.status.Step(terminal.StatusOk, msg)
+		p.status.Close()
+	} else {
+		p.ui.Output(msg, terminal.WithSuccessStyle())
+	}
+}
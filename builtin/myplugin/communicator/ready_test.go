@@ -0,0 +1,99 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWaitForReady(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+
+	ready, err := h.WaitForReady(m, 5)
+	if err != nil || !ready {
+		t.Fatalf("expected the machine to be ready, got %v", err)
+	}
+	lines := m.ui.output()
+	if len(lines) != 2 || lines[0] != "Waiting for machine to be ready..." ||
+		!strings.HasPrefix(lines[1], "Machine is ready (") {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+	var dials int
+	h.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		return nil, &net.OpError{Op: "dial", Net: network,
+			Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	}
+
+	ready, err := h.WaitForReady(m, 1)
+	if ready || !errors.Is(err, ErrConnectionRefused) {
+		t.Fatalf("expected connection refused, got %v", err)
+	}
+	if dials < 2 {
+		t.Fatalf("expected the connection to be retried, got %d attempts", dials)
+	}
+	// The repeated warning is only shown once
+	lines := m.ui.output()
+	if len(lines) != 3 || lines[1] != "Warning: Connection refused. Retrying..." ||
+		!strings.HasPrefix(lines[2], "Machine was not ready after ") {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+func TestWaitForReadyStopsEarly(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(*SSHCommunicator, *testMachine)
+		err   error
+	}{
+		{
+			name:  "auth failed",
+			setup: func(h *SSHCommunicator, m *testMachine) { h.config.Password = "wrong" },
+			err:   ErrAuthFailed,
+		},
+		{
+			name:  "not running",
+			setup: func(h *SSHCommunicator, m *testMachine) { m.provider.state = "poweroff" },
+			err:   ErrNotRunning,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, m, _ := testCommunicator(t)
+			tc.setup(h, m)
+
+			start := time.Now()
+			ready, err := h.WaitForReady(m, 30)
+			if ready || !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("expected waiting to stop early, waited %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestReady(t *testing.T) {
+	h, m, _ := testCommunicator(t)
+	if ready, err := h.Ready(m); err != nil || !ready {
+		t.Fatalf("expected the machine to be ready, got %v", err)
+	}
+
+	h.Reset(m)
+	h.config.Password = "wrong"
+	if ready, err := h.Ready(m); err != nil || ready {
+		t.Fatalf("expected the machine not to be ready, got %v", err)
+	}
+}
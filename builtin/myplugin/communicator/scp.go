@@ -0,0 +1,362 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// scpUpload copies the local file or directory to the destination
+// on the machine. Directories are copied into the destination unless
+// the source ends with "/.", in which case only the contents of the
+// directory are copied. Modes and modification times are preserved.
+func scpUpload(client *ssh.Client, src, dest string, progress *transferProgress) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		if strings.HasSuffix(dest, "/") {
+			dest = path.Join(dest, filepath.Base(src))
+		}
+		if err = remoteMkdir(client, path.Dir(dest)); err != nil {
+			return err
+		}
+		return scpSession(client, "-tp "+shellQuote(dest), func(w io.Writer, r *bufio.Reader) error {
+			if err := scpAck(r); err != nil {
+				return err
+			}
+			// The name is only used by scp when the destination is an
+			// existing directory, like the file is copied by SFTP
+			return scpSend(w, r, src, filepath.Base(src), info, progress)
+		})
+	}
+
+	if err = remoteMkdir(client, dest); err != nil {
+		return err
+	}
+	return scpSession(client, "-rtp "+shellQuote(dest), func(w io.Writer, r *bufio.Reader) error {
+		if err := scpAck(r); err != nil {
+			return err
+		}
+		if filepath.Base(src) != "." {
+			return scpSend(w, r, src, filepath.Base(src), info, progress)
+		}
+		return scpSendContents(w, r, src, progress)
+	})
+}
+
+// scpDownload copies the file or directory at the source on the
+// machine to the local destination. If the destination is an
+// existing directory the source is copied into it. Glob characters
+// in the source are expanded by the shell on the machine.
+func scpDownload(client *ssh.Client, src, dest string, progress *transferProgress) error {
+	arg := shellQuote(src)
+	if hasGlob(src) {
+		arg = shellGlobQuote(src)
+	}
+	return scpSession(client, "-rpf "+arg, func(w io.Writer, r *bufio.Reader) error {
+		return scpReceive(w, r, dest, progress)
+	})
+}
+
+// remoteMkdir creates the directory and any parents on the machine
+func remoteMkdir(client *ssh.Client, dir string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if out, err := session.CombinedOutput("mkdir -p " + shellQuote(dir)); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w: %s",
+			dir, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// scpSession runs scp on the machine with the given arguments
+// and calls fn with the input and output of the remote process
+func scpSession(client *ssh.Client, args string, fn func(io.Writer, *bufio.Reader) error) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	if err = session.Start("scp " + args); err != nil {
+		return err
+	}
+
+	err = fn(stdin, bufio.NewReader(stdout))
+	stdin.Close()
+	if werr := session.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("scp failed: %w: %s", werr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// scpAck reads the response to a message. Any response
+// other than success is followed by an error message.
+func scpAck(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, _ := r.ReadString('\n')
+	return scpError(msg)
+}
+
+// scpError returns the error for a message from the remote
+// scp process, which may already be prefixed by its name
+func scpError(msg string) error {
+	return fmt.Errorf("scp: %s", strings.TrimPrefix(strings.TrimSpace(msg), "scp: "))
+}
+
+// scpSend sends the local file or directory to the remote
+// scp process using the given name
+func scpSend(w io.Writer, r *bufio.Reader, p, name string, info os.FileInfo, progress *transferProgress) error {
+	mtime := info.ModTime().Unix()
+	if _, err := fmt.Fprintf(w, "T%d 0 %d 0\n", mtime, mtime); err != nil {
+		return err
+	}
+	if err := scpAck(r); err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if _, err := fmt.Fprintf(w, "D%04o 0 %s\n", info.Mode().Perm(), name); err != nil {
+			return err
+		}
+		if err := scpAck(r); err != nil {
+			return err
+		}
+		if err := scpSendContents(w, r, p, progress); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(w, "E\n"); err != nil {
+			return err
+		}
+		return scpAck(r)
+	}
+
+	progress.File(p)
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = fmt.Fprintf(w, "C%04o %d %s\n", info.Mode().Perm(), info.Size(), name); err != nil {
+		return err
+	}
+	if err = scpAck(r); err != nil {
+		return err
+	}
+	if _, err = io.CopyN(w, io.TeeReader(f, progress), info.Size()); err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte{0}); err != nil {
+		return err
+	}
+	return scpAck(r)
+}
+
+// scpSendContents sends the entries of the local directory
+func scpSendContents(w io.Writer, r *bufio.Reader, dir string, progress *transferProgress) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if err = scpSend(w, r, p, e.Name(), info, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scpReceive receives files and directories from the remote
+// scp process and writes them to the local target
+func scpReceive(w io.Writer, r *bufio.Reader, target string, progress *transferProgress) error {
+	ack := func() error {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+
+	var dirs []string
+	var dirTimes []time.Time
+	var mtime time.Time
+
+	// entryPath returns the local path of a received entry
+	entryPath := func(name string) (string, error) {
+		if !validEntryName(name) {
+			return "", fmt.Errorf("scp: invalid file name %q", name)
+		}
+		if len(dirs) > 0 {
+			return filepath.Join(dirs[len(dirs)-1], name), nil
+		}
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			return filepath.Join(target, name), nil
+		}
+		return target, nil
+	}
+
+	if err := ack(); err != nil {
+		return err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return fmt.Errorf("scp: invalid empty message")
+		}
+
+		switch line[0] {
+		case 1, 2:
+			return scpError(line[1:])
+		case 'T':
+			fields := strings.Fields(line[1:])
+			if len(fields) != 4 {
+				return fmt.Errorf("scp: invalid message %q", line)
+			}
+			sec, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("scp: invalid message %q", line)
+			}
+			mtime = time.Unix(sec, 0)
+		case 'D':
+			mode, _, name, err := parseSCPEntry(line)
+			if err != nil {
+				return err
+			}
+			p, err := entryPath(name)
+			if err != nil {
+				return err
+			}
+			if err = os.MkdirAll(p, 0700); err != nil {
+				return err
+			}
+			if err = os.Chmod(p, mode|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, p)
+			dirTimes = append(dirTimes, mtime)
+			mtime = time.Time{}
+		case 'E':
+			if len(dirs) == 0 {
+				return fmt.Errorf("scp: unexpected end of directory")
+			}
+			// Directory times are set once their contents
+			// are written so they are not changed again
+			p, t := dirs[len(dirs)-1], dirTimes[len(dirTimes)-1]
+			dirs, dirTimes = dirs[:len(dirs)-1], dirTimes[:len(dirTimes)-1]
+			if !t.IsZero() {
+				os.Chtimes(p, t, t)
+			}
+		case 'C':
+			mode, size, name, err := parseSCPEntry(line)
+			if err != nil {
+				return err
+			}
+			p, err := entryPath(name)
+			if err != nil {
+				return err
+			}
+			if err = ack(); err != nil {
+				return err
+			}
+			progress.File(p)
+			if err = receiveFile(r, p, mode, size, progress); err != nil {
+				return err
+			}
+			if err = scpAck(r); err != nil {
+				return err
+			}
+			if !mtime.IsZero() {
+				os.Chtimes(p, mtime, mtime)
+				mtime = time.Time{}
+			}
+		default:
+			return fmt.Errorf("scp: invalid message %q", line)
+		}
+
+		if err = ack(); err != nil {
+			return err
+		}
+	}
+}
+
+// receiveFile writes size bytes of the file content to the path
+func receiveFile(r io.Reader, p string, mode os.FileMode, size int64, progress *transferProgress) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyN(io.MultiWriter(f, progress), r, size); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(p, mode)
+}
+
+// parseSCPEntry parses a file or directory message of the
+// form "C0644 123 name"
+func parseSCPEntry(line string) (mode os.FileMode, size int64, name string, err error) {
+	parts := strings.SplitN(line[1:], " ", 3)
+	if len(parts) != 3 {
+		return 0, 0, "", fmt.Errorf("scp: invalid message %q", line)
+	}
+	m, err := strconv.ParseUint(parts[0], 8, 32)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("scp: invalid mode in message %q", line)
+	}
+	size, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, "", fmt.Errorf("scp: invalid size in message %q", line)
+	}
+	return os.FileMode(m).Perm(), size, parts[2], nil
+}
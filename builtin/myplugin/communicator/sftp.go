@@ -0,0 +1,192 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// sftpUpload copies the local file or directory to the destination
+// on the machine with the same semantics as scpUpload
+func sftpUpload(client *sftp.Client, src, dest string, progress *transferProgress) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if filepath.Base(src) != "." {
+			dest = path.Join(dest, filepath.Base(src))
+		}
+		return sftpSendDir(client, src, dest, info, progress)
+	}
+
+	if strings.HasSuffix(dest, "/") {
+		dest = path.Join(dest, filepath.Base(src))
+	} else if rinfo, err := client.Stat(dest); err == nil && rinfo.IsDir() {
+		dest = path.Join(dest, filepath.Base(src))
+	}
+	if err = client.MkdirAll(path.Dir(dest)); err != nil {
+		return err
+	}
+	return sftpSendFile(client, src, dest, info, progress)
+}
+
+// sftpDownload copies the file or directory at the source on the
+// machine to the local destination. If the destination is an
+// existing directory the source is copied into it.
+func sftpDownload(client *sftp.Client, src, dest string, progress *transferProgress) error {
+	info, err := client.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if linfo, err := os.Stat(dest); err == nil && linfo.IsDir() {
+		dest = filepath.Join(dest, path.Base(src))
+	}
+	if info.IsDir() {
+		return sftpReceiveDir(client, src, dest, info, progress)
+	}
+	return sftpReceiveFile(client, src, dest, info, progress)
+}
+
+// sftpSendDir creates the directory on the machine and sends
+// the entries of the local directory into it
+func sftpSendDir(client *sftp.Client, dir, dest string, info os.FileInfo, progress *transferProgress) error {
+	if err := client.MkdirAll(dest); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		einfo, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(dest, e.Name())
+		if einfo.IsDir() {
+			err = sftpSendDir(client, p, target, einfo, progress)
+		} else {
+			err = sftpSendFile(client, p, target, einfo, progress)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Directory times are set once their contents
+	// are written so they are not changed again
+	return sftpSetAttrs(client, dest, info)
+}
+
+// sftpSendFile writes the local file to the path on the machine
+func sftpSendFile(client *sftp.Client, p, dest string, info os.FileInfo, progress *transferProgress) error {
+	progress.File(p)
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rf, err := client.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(rf, io.TeeReader(f, progress)); err != nil {
+		rf.Close()
+		return err
+	}
+	if err = rf.Close(); err != nil {
+		return err
+	}
+	return sftpSetAttrs(client, dest, info)
+}
+
+// sftpSetAttrs sets the mode and modification time
+// of the path on the machine from the local file
+func sftpSetAttrs(client *sftp.Client, p string, info os.FileInfo) error {
+	if err := client.Chmod(p, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return client.Chtimes(p, info.ModTime(), info.ModTime())
+}
+
+// sftpReceiveDir creates the local directory and receives the
+// entries of the directory on the machine into it
+func sftpReceiveDir(client *sftp.Client, dir, dest string, info os.FileInfo, progress *transferProgress) error {
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return err
+	}
+
+	entries, err := client.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !validEntryName(e.Name()) {
+			return fmt.Errorf("sftp: invalid file name %q in %s", e.Name(), dir)
+		}
+		p := path.Join(dir, e.Name())
+		// Links are followed like they are by scp
+		if e.Mode()&os.ModeSymlink != 0 {
+			if e, err = client.Stat(p); err != nil {
+				return err
+			}
+		}
+		target := filepath.Join(dest, e.Name())
+		if e.IsDir() {
+			err = sftpReceiveDir(client, p, target, e, progress)
+		} else {
+			err = sftpReceiveFile(client, p, target, e, progress)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = os.Chmod(dest, info.Mode().Perm()|0700); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// sftpReceiveFile writes the file on the machine to the local path
+func sftpReceiveFile(client *sftp.Client, p, dest string, info os.FileInfo, progress *transferProgress) error {
+	progress.File(p)
+
+	rf, err := client.Open(p)
+	if err != nil {
+		return err
+	}
+	defer rf.Close()
+
+	mode := info.Mode().Perm()
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(io.MultiWriter(f, progress), rf); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(dest, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,293 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	plugincore "github.com/hashicorp/vagrant-plugin-sdk/core"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	defaultConnectTimeout = 15 * time.Second
+	defaultKeepAlive      = 5 * time.Second
+	defaultPort           = "22"
+	defaultUsername       = "vagrant"
+
+	// aliveTimeout is how long an existing connection
+	// may take to respond before it is replaced
+	aliveTimeout = 5 * time.Second
+)
+
+// errNoSSHInfo is returned when the provider has no SSH
+// information for the machine, such as when it is not running
+var errNoSSHInfo = errors.New("SSH information is not available for the machine")
+
+// Dialer opens a network connection to the address
+type Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// connect returns the connection to the machine, reusing an
+// existing connection when it is still alive. The lock is not held
+// while checking or establishing a connection so a slow machine
+// does not block other requests or Reset.
+func (h *SSHCommunicator) connect(ctx context.Context, machine plugincore.Machine) (*ssh.Client, error) {
+	id, err := machine.ID()
+	if err != nil {
+		return nil, err
+	}
+
+	h.m.Lock()
+	client, ok := h.clients[id]
+	h.m.Unlock()
+
+	if ok {
+		if alive(client) {
+			return client, nil
+		}
+		h.m.Lock()
+		if h.clients[id] == client {
+			delete(h.clients, id)
+		}
+		h.m.Unlock()
+		client.Close()
+	}
+
+	info, err := sshInfo(machine)
+	if err != nil {
+		return nil, err
+	}
+	client, err = h.dial(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+
+	h.m.Lock()
+	// Another request may have connected in the meantime
+	if existing, ok := h.clients[id]; ok {
+		h.m.Unlock()
+		client.Close()
+		return existing, nil
+	}
+	if h.clients == nil {
+		h.clients = map[string]*ssh.Client{}
+	}
+	h.clients[id] = client
+	h.m.Unlock()
+
+	h.keepAlive(client)
+	return client, nil
+}
+
+// alive returns if the connection responds to a keepalive
+// request within the probe timeout
+func alive(client *ssh.Client) bool {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+
+	timer := time.NewTimer(aliveTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err == nil
+	case <-timer.C:
+		return false
+	}
+}
+
+// sshInfo returns the SSH information of the machine from its provider
+func sshInfo(machine plugincore.Machine) (*plugincore.SshInfo, error) {
+	provider, err := machine.Provider()
+	if err != nil {
+		return nil, err
+	}
+	info, err := provider.SshInfo()
+	if err != nil {
+		return nil, err
+	}
+	if info == nil || info.Host == "" {
+		return nil, errNoSSHInfo
+	}
+	return info, nil
+}
+
+// dial establishes a new connection to the machine
+func (h *SSHCommunicator) dial(ctx context.Context, info *plugincore.SshInfo) (*ssh.Client, error) {
+	config, err := h.clientConfig(info)
+	if err != nil {
+		return nil, err
+	}
+
+	port := info.Port
+	if port == "" {
+		port = defaultPort
+	}
+	addr := net.JoinHostPort(info.Host, port)
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	dialer := h.Dialer
+	if dialer == nil {
+		dialer = (&net.Dialer{}).DialContext
+	}
+	conn, err := dialer(ctx, "tcp", addr)
+	if err != nil {
+		return nil, connectError(err)
+	}
+
+	// The handshake is bounded by the same deadline as the dial
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, connectError(err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// clientConfig returns the SSH client configuration
+// used to connect to the machine
+func (h *SSHCommunicator) clientConfig(info *plugincore.SshInfo) (*ssh.ClientConfig, error) {
+	user := info.Username
+	if user == "" {
+		user = h.config.Username
+	}
+	if user == "" {
+		user = defaultUsername
+	}
+
+	timeout := h.config.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+
+	// Host keys are not verified by default, matching the
+	// behavior of the Ruby SSH communicator
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if h.config.KnownHostsPath != "" {
+		var err error
+		if hostKeyCallback, err = knownhosts.New(h.config.KnownHostsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var auth []ssh.AuthMethod
+	signers, err := h.signers(info)
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if h.config.Password != "" {
+		auth = append(auth, ssh.Password(h.config.Password))
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}, nil
+}
+
+// signers loads the private keys used for authentication. When
+// no keys are configured the Vagrant insecure keys are used.
+func (h *SSHCommunicator) signers(info *plugincore.SshInfo) ([]ssh.Signer, error) {
+	var paths []string
+	if info.PrivateKeyPath != "" {
+		paths = append(paths, info.PrivateKeyPath)
+	}
+	paths = append(paths, h.config.PrivateKeyPaths...)
+
+	required := true
+	if len(paths) == 0 && h.config.Password == "" {
+		paths = insecureKeyPaths()
+		required = false
+	}
+
+	var signers []ssh.Signer
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			if !required {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read private key %s: %w", p, err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			if !required {
+				continue
+			}
+			return nil, fmt.Errorf("failed to parse private key %s: %w", p, err)
+		}
+		signers = append(signers, signer)
+	}
+
+	return signers, nil
+}
+
+// insecureKeyPaths returns the paths of the Vagrant
+// insecure private keys
+func insecureKeyPaths() []string {
+	home := os.Getenv("VAGRANT_HOME")
+	if home == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		home = filepath.Join(userHome, ".vagrant.d")
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(home, "insecure_private_keys", "*"))
+	return append(paths, filepath.Join(home, "insecure_private_key"))
+}
+
+// keepAlive sends keepalive requests on the connection
+// until it is closed
+func (h *SSHCommunicator) keepAlive(client *ssh.Client) {
+	interval := h.config.KeepAlive
+	if interval == 0 {
+		interval = defaultKeepAlive
+	}
+	if interval < 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
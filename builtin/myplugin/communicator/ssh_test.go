@@ -0,0 +1,73 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSSHConfigUsername(t *testing.T) {
+	h, m, s := testCommunicator(t)
+	h.config.Username = "deploy"
+
+	if _, err := h.Test(m, []string{"true"}); err != nil {
+		t.Fatal(err)
+	}
+	h.Reset(m)
+
+	// The user of the machine takes precedence
+	m.provider.info.Username = "machine"
+	if _, err := h.Test(m, []string{"true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	logins := s.logins()
+	if len(logins) != 2 || logins[0] != "deploy" || logins[1] != "machine" {
+		t.Fatalf("unexpected logins %q", logins)
+	}
+}
+
+func TestSSHConfigKnownHosts(t *testing.T) {
+	h, m, s := testCommunicator(t)
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := ssh.NewSignerFromKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		key  ssh.PublicKey
+		err  error
+	}{
+		{name: "known", key: s.signer.PublicKey()},
+		{name: "mismatch", key: otherSigner.PublicKey(), err: ErrHostKeyMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h.Reset(m)
+			h.config.KnownHostsPath = filepath.Join(t.TempDir(), "known_hosts")
+			line := knownhosts.Line([]string{"127.0.0.1"}, tc.key) + "\n"
+			if err := os.WriteFile(h.config.KnownHostsPath, []byte(line), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := h.Test(m, []string{"true"})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
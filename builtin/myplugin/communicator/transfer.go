@@ -0,0 +1,205 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	plugincore "github.com/hashicorp/vagrant-plugin-sdk/core"
+	"github.com/hashicorp/vagrant-plugin-sdk/terminal"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// upload copies the local source to the destination on the machine
+// using SFTP, or SCP when the machine does not support SFTP. A source
+// containing glob characters uploads all matches into the destination.
+func upload(client *ssh.Client, src, dest string, progress *transferProgress) error {
+	sources := []string{src}
+	if hasGlob(src) {
+		var err error
+		if sources, err = filepath.Glob(src); err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return fmt.Errorf("no files match %s", src)
+		}
+		if !strings.HasSuffix(dest, "/") {
+			dest += "/"
+		}
+	}
+	dest = remotePath(dest)
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		// SFTP is an optional subsystem of the SSH server
+		for _, s := range sources {
+			if err = scpUpload(client, s, dest, progress); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	defer sc.Close()
+
+	for _, s := range sources {
+		if err = sftpUpload(sc, s, dest, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// download copies the source on the machine to the local destination
+// using SFTP, or SCP when the machine does not support SFTP. A source
+// containing glob characters downloads all matches into the destination.
+func download(client *ssh.Client, pattern, dest string, progress *transferProgress) error {
+	src := remotePath(pattern)
+	if hasGlob(src) {
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return err
+		}
+	}
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		// SFTP is an optional subsystem of the SSH server
+		return scpDownload(client, src, dest, progress)
+	}
+	defer sc.Close()
+
+	sources := []string{src}
+	if hasGlob(src) {
+		if sources, err = sc.Glob(src); err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return fmt.Errorf("no files match %s", pattern)
+		}
+	}
+
+	for _, s := range sources {
+		if err = sftpDownload(sc, s, dest, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remotePath returns the path on the machine with a leading "~"
+// replaced by a path relative to the directory the session starts
+// in, which is the home directory of the user. The "~" would not
+// be expanded once the path is quoted.
+func remotePath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		return "." + p[1:]
+	}
+	return p
+}
+
+// validEntryName returns if the name of an entry received from the
+// machine is a single path element. Other names could write outside
+// of the local destination.
+func validEntryName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// hasGlob returns if the path contains glob characters
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// transferProgress reports the progress of an upload or download.
+// A status is updated with each file when the UI is interactive,
+// otherwise only the result is output.
+type transferProgress struct {
+	ui     terminal.UI
+	status terminal.Status
+	verb   string
+	start  time.Time
+	files  int
+	bytes  int64
+}
+
+func newTransferProgress(machine plugincore.Machine, verb string) *transferProgress {
+	p := &transferProgress{
+		verb:  verb,
+		start: time.Now(),
+	}
+	if ui, err := machine.UI(); err == nil && ui != nil {
+		p.ui = ui
+		if ui.Interactive() && !ui.MachineReadable() {
+			p.status = ui.Status()
+		}
+	}
+	return p
+}
+
+// File reports a file is being transferred
+func (p *transferProgress) File(name string) {
+	p.files++
+	if p.status != nil {
+		p.status.Update(fmt.Sprintf("%sing %s (%s, %s)",
+			p.verb, name, p.count(), formatBytes(p.bytes)))
+	}
+}
+
+// Write counts the bytes transferred
+func (p *transferProgress) Write(b []byte) (int, error) {
+	p.bytes += int64(len(b))
+	return len(b), nil
+}
+
+// Finish outputs the result of the transfer
+func (p *transferProgress) Finish(err error) {
+	if p.ui == nil {
+		return
+	}
+
+	elapsed := time.Since(p.start).Round(time.Second)
+	if err != nil {
+		msg := fmt.Sprintf("%s failed after %s: %s", p.verb, elapsed, err)
+		if p.status != nil {
+			p.status.Step(terminal.StatusError, msg)
+			p.status.Close()
+		} else {
+			p.ui.Output("%s", msg, terminal.WithErrorStyle())
+		}
+		return
+	}
+
+	msg := fmt.Sprintf("%sed %s (%s in %s)", p.verb, p.count(), formatBytes(p.bytes), elapsed)
+	if p.status != nil {
+		p.status.Step(terminal.StatusOk, msg)
+		p.status.Close()
+	} else {
+		p.ui.Output(msg, terminal.WithSuccessStyle())
+	}
+}
+
+// count returns the number of files transferred
+func (p *transferProgress) count() string {
+	if p.files == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", p.files)
+}
+
+// formatBytes formats the size using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
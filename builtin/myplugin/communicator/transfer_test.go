@@ -0,0 +1,279 @@
+// Copyright IBM Corp. 2010, 2025
+// SPDX-License-Identifier: BUSL-1.1
+
+package communicator
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-argmapper"
+	"github.com/hashicorp/go-hclog"
+	plugincore "github.com/hashicorp/vagrant-plugin-sdk/core"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+var testModTime = time.Unix(1500000000, 0)
+
+type transferInput = struct {
+	argmapper.Struct
+	Machine     plugincore.Machine `argmapper:",typeOnly"`
+	Logger      hclog.Logger       `argmapper:",typeOnly"`
+	Source      string
+	Destination string
+}
+
+// testTransferCommunicator returns a communicator connected to a
+// test server with SFTP, or without it so SCP is used
+func testTransferCommunicator(t *testing.T, withSFTP bool) (*SSHCommunicator, *testMachine, *testServer) {
+	if _, err := exec.LookPath("scp"); err != nil && !withSFTP {
+		t.Skip("scp is not installed")
+	}
+
+	h, m, s := testCommunicator(t)
+	if withSFTP {
+		s.subsystems["sftp"] = func(ch ssh.Channel) {
+			server, err := sftp.NewServer(ch, sftp.WithServerWorkingDirectory(s.dir))
+			if err != nil {
+				return
+			}
+			server.Serve()
+		}
+	}
+	return h, m, s
+}
+
+// testTree creates a local directory tree to transfer
+func testTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for p, mode := range map[string]os.FileMode{
+		"tree/a.txt":    0640,
+		"tree/sub/b.sh": 0755,
+		"x.log":         0600,
+		"y.log":         0644,
+	} {
+		p = filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(filepath.Base(p)), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{"tree/a.txt", "tree/sub"} {
+		if err := os.Chtimes(filepath.Join(dir, p), testModTime, testModTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// checkTree checks the tree was copied into the directory
+// with its modes and modification times
+func checkTree(t *testing.T, dir string) {
+	t.Helper()
+
+	for p, mode := range map[string]os.FileMode{
+		"tree/a.txt":    0640,
+		"tree/sub/b.sh": 0755,
+	} {
+		info, err := os.Stat(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("expected %s to have mode %s, got %s", p, mode, info.Mode().Perm())
+		}
+	}
+	for _, p := range []string{"tree/a.txt", "tree/sub"} {
+		info, err := os.Stat(filepath.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(testModTime) {
+			t.Errorf("expected %s to be modified at %s, got %s", p, testModTime, info.ModTime())
+		}
+	}
+}
+
+func checkFile(t *testing.T, p, content string) {
+	t.Helper()
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("expected %s to contain %q, got %q", p, content, b)
+	}
+}
+
+func testTransfers(t *testing.T, withSFTP bool) {
+	h, m, s := testTransferCommunicator(t, withSFTP)
+	src := testTree(t)
+	transfer := func(t *testing.T, fn func(transferInput) error, source, dest string) {
+		t.Helper()
+		err := fn(transferInput{
+			Machine:     m,
+			Logger:      hclog.NewNullLogger(),
+			Source:      source,
+			Destination: dest,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("upload directory", func(t *testing.T) {
+		transfer(t, h.Upload, filepath.Join(src, "tree"), "~/dir")
+		checkTree(t, filepath.Join(s.dir, "dir"))
+	})
+
+	t.Run("upload directory contents", func(t *testing.T) {
+		transfer(t, h.Upload, filepath.Join(src, "tree")+"/.", "~/contents")
+		checkFile(t, filepath.Join(s.dir, "contents", "sub", "b.sh"), "b.sh")
+	})
+
+	t.Run("upload glob", func(t *testing.T) {
+		transfer(t, h.Upload, filepath.Join(src, "*.log"), "~/logs")
+		checkFile(t, filepath.Join(s.dir, "logs", "x.log"), "x.log")
+		checkFile(t, filepath.Join(s.dir, "logs", "y.log"), "y.log")
+	})
+
+	t.Run("upload file", func(t *testing.T) {
+		transfer(t, h.Upload, filepath.Join(src, "x.log"), "~/file/renamed.log")
+		checkFile(t, filepath.Join(s.dir, "file", "renamed.log"), "x.log")
+
+		// An existing directory receives the file
+		transfer(t, h.Upload, filepath.Join(src, "y.log"), "~/file")
+		checkFile(t, filepath.Join(s.dir, "file", "y.log"), "y.log")
+	})
+
+	t.Run("download directory", func(t *testing.T) {
+		dest := t.TempDir()
+		transfer(t, h.Download, "~/dir/tree", dest)
+		checkTree(t, dest)
+	})
+
+	t.Run("download file", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "renamed.log")
+		transfer(t, h.Download, "~/logs/x.log", dest)
+		checkFile(t, dest, "x.log")
+	})
+
+	t.Run("download glob", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "logs")
+		transfer(t, h.Download, "~/logs/*.log", dest)
+		checkFile(t, filepath.Join(dest, "x.log"), "x.log")
+		checkFile(t, filepath.Join(dest, "y.log"), "y.log")
+	})
+
+	t.Run("download missing", func(t *testing.T) {
+		err := h.Download(transferInput{
+			Machine:     m,
+			Logger:      hclog.NewNullLogger(),
+			Source:      "~/missing/*.log",
+			Destination: t.TempDir(),
+		})
+		if err == nil {
+			t.Fatal("expected error downloading missing files")
+		}
+	})
+}
+
+func TestTransfersSFTP(t *testing.T) {
+	testTransfers(t, true)
+}
+
+func TestTransfersSCP(t *testing.T) {
+	testTransfers(t, false)
+}
+
+// testFileInfo describes a file served by hostileHandler
+type testFileInfo struct {
+	name string
+	mode os.FileMode
+}
+
+func (i testFileInfo) Name() string       { return i.name }
+func (i testFileInfo) Size() int64        { return int64(len("escaped")) }
+func (i testFileInfo) Mode() os.FileMode  { return i.mode }
+func (i testFileInfo) ModTime() time.Time { return testModTime }
+func (i testFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i testFileInfo) Sys() any           { return nil }
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(f []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(f, l[offset:])
+	if offset+int64(n) == int64(len(l)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// hostileHandler serves directories containing a single
+// entry with the name, which may escape the directory
+type hostileHandler struct {
+	name string
+}
+
+func (h hostileHandler) Fileread(*sftp.Request) (io.ReaderAt, error) {
+	return strings.NewReader("escaped"), nil
+}
+
+func (h hostileHandler) Filewrite(*sftp.Request) (io.WriterAt, error) {
+	return nil, os.ErrPermission
+}
+
+func (h hostileHandler) Filecmd(*sftp.Request) error {
+	return os.ErrPermission
+}
+
+func (h hostileHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if r.Method == "List" {
+		return listerAt{testFileInfo{name: h.name, mode: 0644}}, nil
+	}
+	return listerAt{testFileInfo{name: path.Base(r.Filepath), mode: os.ModeDir | 0755}}, nil
+}
+
+func TestDownloadSFTPInvalidName(t *testing.T) {
+	// The client only keeps the last element of a name, so
+	// "sub/.." is received as ".."
+	for _, name := range []string{"sub/..", "/", `..\escaped`} {
+		t.Run(name, func(t *testing.T) {
+			h, m, s := testTransferCommunicator(t, true)
+			s.subsystems["sftp"] = func(ch ssh.Channel) {
+				handler := hostileHandler{name: name}
+				sftp.NewRequestServer(ch, sftp.Handlers{
+					FileGet:  handler,
+					FilePut:  handler,
+					FileCmd:  handler,
+					FileList: handler,
+				}).Serve()
+			}
+
+			err := h.Download(transferInput{
+				Machine:     m,
+				Logger:      hclog.NewNullLogger(),
+				Source:      "~/dir",
+				Destination: filepath.Join(t.TempDir(), "dest"),
+			})
+			if err == nil || !strings.Contains(err.Error(), "invalid file name") {
+				t.Fatalf("expected invalid file name error, got %v", err)
+			}
+		})
+	}
+}
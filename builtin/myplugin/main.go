@@ -23,7 +23,7 @@ var CommandOptions = []sdk.Option{
 	sdk.WithComponents(
 		// &Provider{},
 		&host.AlwaysTrueHost{},
-		&communicator.DummyCommunicator{},
+		&communicator.SSHCommunicator{},
 		&push.Encouragement{},
 	),
 	sdk.WithComponent(&command.Command{}, &component.CommandOptions{
@@ -9,7 +9,18 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
+// StructToCommunincatorOptions decodes the communicator options from
+// their snake case names, such as "working_dir". Values are converted
+// where needed since numbers in a struct are always floats.
 func StructToCommunincatorOptions(in *structpb.Struct) (*pb.CommunicatorOptions, error) {
 	var result pb.CommunicatorOptions
-	return &result, mapstructure.Decode(in.AsMap(), &result)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:          "json",
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, decoder.Decode(in.AsMap())
 }